@@ -0,0 +1,86 @@
+package main
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/adapter"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/config"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/diff"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/logging"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/storage"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const diffCommand = "diff"
+
+// runDiff compares two previous runs given as report files or snapshot IDs.
+func runDiff(arguments []string) {
+	flagSet := flag.NewFlagSet(os.Args[0]+" "+diffCommand, flag.ExitOnError)
+	snapshotDB := flagSet.String("snapshot-db", config.Config.SnapshotDB, "SQLite database used to resolve snapshot IDs")
+	format := flagSet.String("format", "text", "Output format: text or json")
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), "Usage of %s: [options] <before> <after>\n", flagSet.Name())
+		fmt.Fprintf(flagSet.Output(), "\t<before> and <after> are CSV/JSON/NDJSON reports or snapshot IDs from --snapshot-db.\n\n")
+		flagSet.PrintDefaults()
+	}
+	flagSet.Parse(arguments)
+
+	if flagSet.NArg() != 2 {
+		flagSet.Usage()
+		os.Exit(1)
+	}
+
+	before, err := loadRun(flagSet.Arg(0), *snapshotDB)
+	if err != nil {
+		logging.Log.Fatal("error loading run: ", err)
+		return
+	}
+	after, err := loadRun(flagSet.Arg(1), *snapshotDB)
+	if err != nil {
+		logging.Log.Fatal("error loading run: ", err)
+		return
+	}
+
+	report, err := diff.Compare(before, after)
+	if err != nil {
+		logging.Log.Fatal("error comparing runs: ", err)
+		return
+	}
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	case "text":
+		err = report.WriteText(os.Stdout)
+	default:
+		err = fmt.Errorf("unsupported diff format %q", *format)
+	}
+	if err != nil {
+		logging.Log.Fatal("error writing diff: ", err)
+	}
+}
+
+// loadRun reads a run from a report file, or from the snapshot database when the reference is a snapshot ID.
+func loadRun(reference, snapshotDB string) ([]model.ClusterWithAgentMetadata, error) {
+	snapshotID, err := strconv.ParseInt(reference, 10, 64)
+	if err != nil || snapshotDB == "" {
+		return adapter.ReadFromFile(reference)
+	}
+
+	store, err := storage.OpenSnapshotStore(snapshotDB)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	snapshot, err := store.LoadSnapshot(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.Clusters, nil
+}
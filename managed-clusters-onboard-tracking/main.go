@@ -2,22 +2,53 @@ package main
 
 import (
 	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/adapter"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/classifier"
 	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/client"
 	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/config"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/gate"
 	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/logging"
 	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/storage"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/version"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/worker"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
 var mutex sync.Mutex
 
+const (
+	agentTask   = "agent"
+	runtimeTask = "runtime"
+)
+
+// Exit codes returned after the report has been written. When several apply, an interrupted run
+// wins over a gate violation, which wins over failed lookups.
+const (
+	exitInterrupted    = 1
+	exitPartialFailure = 2
+	exitGateViolation  = 3
+)
+
+// enrichmentFailure records a failed lookup, agentTask or runtimeTask, for the cluster at index in the enrichment input.
+type enrichmentFailure struct {
+	index  int
+	lookup string
+	err    error
+}
+
 func init() {
 	errs := config.LoadConfig()
 	logging.InitLogger(config.Config)
@@ -31,40 +62,262 @@ func init() {
 }
 
 func main() {
-	args := parseArguments()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case serveCommand:
+			runServe(os.Args[2:])
+			return
+		case diffCommand:
+			runDiff(os.Args[2:])
+			return
+		case trendCommand:
+			runTrend(os.Args[2:])
+			return
+		}
+	}
+	runReport(os.Args[1:])
+}
 
-	var sysdigClient client.API = &client.Client{
-		SecureApiToken: config.Config.SecureApiToken,
-		BaseURL:        config.Config.ApiURL,
-		MaxRetries:     config.Config.ApiMaxRetries,
+// runReport collects the cluster data once and writes it to the output file.
+func runReport(arguments []string) {
+	flagSet, args := newFlagSet(os.Args[0])
+	flagSet.StringVar(&args.Output, "output", "clusters.csv", "Output file name")
+	flagSet.StringVar(&args.Format, "format", "", "Output format: csv, json, ndjson, xlsx, html, markdown or prometheus (defaults to the output file extension)")
+	flagSet.StringVar(&args.NodesOutput, "nodes-output", "", "Optional node-level report file listing every agent of each cluster (csv, json or ndjson, from the file extension)")
+	flagSet.StringVar(&args.WorkloadsOutput, "workloads-output", "", "Optional workload-level report file listing the namespaces and workloads with runtime results of each cluster (csv, json or ndjson, from the file extension, implies --runtime-details)")
+	flagSet.Int64Var(&args.FromSnapshot, "from-snapshot", 0, "Rebuild the report from a stored snapshot ID instead of calling the API (requires --snapshot-db)")
+	flagSet.Float64Var(&args.Thresholds.MinNodeCoverage, "min-node-coverage", gate.Disabled, "Fail with exit code 3 when the percentage of connected nodes is below this value")
+	flagSet.IntVar(&args.Thresholds.MaxOutOfDate, "max-out-of-date", gate.Disabled, "Fail with exit code 3 when more clusters than this have at least one out of date agent")
+	flagSet.IntVar(&args.Thresholds.MaxStale, "max-stale", gate.Disabled, "Fail with exit code 3 when more clusters than this have stale agents")
+	flagSet.Var(&args.Thresholds.RequireRuntime, "require-runtime", "Fail with exit code 3 when a cluster matching dimension=value, such as env=production, has no runtime enabled (repeatable)")
+	flagSet.Parse(arguments)
+
+	// Workloads are only known once every runtime result page was fetched
+	if args.WorkloadsOutput != "" {
+		args.RuntimeDetails = true
+	}
+
+	if args.FromSnapshot != 0 {
+		runReportFromSnapshot(*args)
+		return
 	}
+
+	sysdigClient := client.NewClient(config.Config)
 	logging.Log.Debugf("Created HTTP client with following configs: %+v", sysdigClient)
 
-	start := time.Now()
+	// Cancel every outstanding request on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	clusters, err := sysdigClient.GetClusterData(args.Limit, args.Filter, args.Connected)
+	classificationRules, err := loadClassificationRules(args.RulesFile)
 	if err != nil {
-		logging.Log.Fatal("error getting cluster data: ", err)
+		logging.Log.Fatal("error loading classification rules: ", err)
 		return
 	}
 
-	clustersWithAgentInfo, runtimeClusters, err := getExtraFeaturesInformationFromClusters(clusters, sysdigClient)
+	versionPolicy, err := loadVersionPolicy(args.VersionPolicyFile)
 	if err != nil {
-		logging.Log.Fatal("error enriching cluster data: ", err)
+		logging.Log.Fatal("error loading agent version policy: ", err)
 		return
 	}
 
-	mergeClusterInfoWithRuntime(clustersWithAgentInfo, runtimeClusters)
+	start := time.Now()
+
+	clustersWithAgentInfo, err := collectClusterData(ctx, sysdigClient, *args, classificationRules, versionPolicy)
+	if err != nil {
+		logging.Log.Fatal(err)
+		return
+	}
 
 	getMetricsData(clustersWithAgentInfo)
 
-	// Write to CSV
-	err = adapter.WriteToCSV(args.Output, clustersWithAgentInfo)
-	if err != nil {
-		logging.Log.Info("Failed to write to CSV:", err)
+	// Interrupted runs are partial, they are not stored to keep the history comparable
+	if args.SnapshotDB != "" && ctx.Err() == nil {
+		saveSnapshot(args.SnapshotDB, start, clustersWithAgentInfo)
 	}
+
+	writeReport(*args, clustersWithAgentInfo)
+
 	end_time := time.Now()
 	logging.Log.Info("Execution time: ", end_time.Sub(start))
+	logging.Log.Info("Effective API rate limit (requests/second): ", sysdigClient.EffectiveRateLimit())
+
+	if ctx.Err() != nil {
+		logging.Log.Warn("Execution interrupted, output contains partial results")
+		os.Exit(exitInterrupted)
+	}
+
+	// Both reports are always printed, a gate violation takes precedence over failed lookups
+	failedClusters := logFailureSummary(clustersWithAgentInfo)
+	if !evaluateThresholds(args.Thresholds, clustersWithAgentInfo) {
+		os.Exit(exitGateViolation)
+	}
+	if failedClusters > 0 {
+		os.Exit(exitPartialFailure)
+	}
+}
+
+// runReportFromSnapshot writes the report of a stored snapshot as it was when collected.
+func runReportFromSnapshot(args CommandLineArgs) {
+	if args.SnapshotDB == "" {
+		logging.Log.Fatal("--from-snapshot requires --snapshot-db")
+		return
+	}
+	store, err := storage.OpenSnapshotStore(args.SnapshotDB)
+	if err != nil {
+		logging.Log.Fatal("error opening snapshot database: ", err)
+		return
+	}
+	defer store.Close()
+
+	snapshot, err := store.LoadSnapshot(args.FromSnapshot)
+	if err != nil {
+		logging.Log.Fatal("error loading snapshot: ", err)
+		return
+	}
+	logging.Log.Infof("Loaded snapshot %d taken at %s", snapshot.ID, snapshot.TakenAt)
+
+	getMetricsData(snapshot.Clusters)
+	writeReport(args, snapshot.Clusters)
+
+	if !evaluateThresholds(args.Thresholds, snapshot.Clusters) {
+		os.Exit(exitGateViolation)
+	}
+}
+
+// evaluateThresholds runs the configured compliance checks, printing the violation report,
+// and returns whether every check passed.
+func evaluateThresholds(thresholds gate.Thresholds, clusters []model.ClusterWithAgentMetadata) bool {
+	if !thresholds.Enabled() {
+		return true
+	}
+
+	violations, err := gate.Evaluate(clusters, thresholds)
+	if err != nil {
+		logging.Log.Fatal("error evaluating thresholds: ", err)
+		return false
+	}
+	gate.WriteViolations(os.Stderr, violations)
+	return len(violations) == 0
+}
+
+// writeReport writes the report in the requested format, inferred from the output extension by default.
+func writeReport(args CommandLineArgs, clusters []model.ClusterWithAgentMetadata) {
+	format := args.Format
+	if format == "" {
+		format = adapter.FormatFromFileName(args.Output)
+	}
+	writer, err := adapter.NewWriter(format)
+	if err != nil {
+		logging.Log.Fatal("error selecting output format: ", err)
+		return
+	}
+	err = adapter.WriteToFile(args.Output, writer, clusters)
+	if err != nil {
+		logging.Log.Info("Failed to write report:", err)
+	}
+
+	if args.NodesOutput != "" {
+		writeNodeReport(args.NodesOutput, clusters)
+	}
+	if args.WorkloadsOutput != "" {
+		writeWorkloadReport(args.WorkloadsOutput, clusters)
+	}
+}
+
+// writeNodeReport writes every agent record of the clusters to fileName.
+func writeNodeReport(fileName string, clusters []model.ClusterWithAgentMetadata) {
+	writer, err := adapter.NewNodeWriter(adapter.FormatFromFileName(fileName))
+	if err != nil {
+		logging.Log.Fatal("error selecting node report format: ", err)
+		return
+	}
+	if err := adapter.WriteToFile(fileName, writer, clusters); err != nil {
+		logging.Log.Info("Failed to write node report:", err)
+	}
+}
+
+// writeWorkloadReport writes every workload with runtime results of the clusters to fileName.
+func writeWorkloadReport(fileName string, clusters []model.ClusterWithAgentMetadata) {
+	writer, err := adapter.NewWorkloadWriter(adapter.FormatFromFileName(fileName))
+	if err != nil {
+		logging.Log.Fatal("error selecting workload report format: ", err)
+		return
+	}
+	if err := adapter.WriteToFile(fileName, writer, clusters); err != nil {
+		logging.Log.Info("Failed to write workload report:", err)
+	}
+}
+
+// saveSnapshot stores the collected clusters in the snapshot database, logging failures without aborting.
+func saveSnapshot(snapshotDB string, takenAt time.Time, clusters []model.ClusterWithAgentMetadata) {
+	store, err := storage.OpenSnapshotStore(snapshotDB)
+	if err != nil {
+		logging.Log.Errorf("Failed to open snapshot database %s. Error: %v", snapshotDB, err)
+		return
+	}
+	defer store.Close()
+
+	snapshotID, err := store.SaveSnapshot(takenAt, clusters)
+	if err != nil {
+		logging.Log.Errorf("Failed to store snapshot in %s. Error: %v", snapshotDB, err)
+		return
+	}
+	logging.Log.Infof("Stored snapshot %d in %s", snapshotID, snapshotDB)
+}
+
+// collectClusterData fetches the clusters and enriches them with agent, runtime, classification and version compliance data.
+func collectClusterData(ctx context.Context, sysdigClient client.API, args CommandLineArgs, rules *classifier.Rules, versionPolicy *version.Policy) ([]model.ClusterWithAgentMetadata, error) {
+	clusters, err := sysdigClient.GetClusterData(ctx, args.Limit, args.Filter, args.Connected)
+	if err != nil {
+		return nil, fmt.Errorf("error getting cluster data: %w", err)
+	}
+
+	clustersWithAgentInfo, runtimeClusters, err := getExtraFeaturesInformationFromClusters(ctx, clusters, sysdigClient, args.RuntimeDetails, args.Concurrency, args.PartialResults)
+	if err != nil {
+		return nil, fmt.Errorf("error enriching cluster data: %w", err)
+	}
+
+	mergeClusterInfoWithRuntime(clustersWithAgentInfo, runtimeClusters)
+
+	classifyClusters(clustersWithAgentInfo, rules)
+
+	evaluateVersionCompliance(clustersWithAgentInfo, versionPolicy)
+
+	detectStaleAgents(clustersWithAgentInfo, time.Now(), args.StaleAfter)
+
+	return clustersWithAgentInfo, nil
+}
+
+func loadClassificationRules(rulesFile string) (*classifier.Rules, error) {
+	if rulesFile == "" {
+		return classifier.DefaultRules(), nil
+	}
+	return classifier.LoadRules(rulesFile, adapter.IsReportColumn)
+}
+
+// loadVersionPolicy returns a nil policy when no file is given, leaving compliance unevaluated.
+func loadVersionPolicy(policyFile string) (*version.Policy, error) {
+	if policyFile == "" {
+		return nil, nil
+	}
+	return version.LoadPolicy(policyFile)
+}
+
+// logFailureSummary logs every cluster with failed lookups and returns how many clusters failed.
+func logFailureSummary(clusters []model.ClusterWithAgentMetadata) int {
+	failedClusters := 0
+	for _, cluster := range clusters {
+		if len(cluster.Errors) == 0 {
+			continue
+		}
+		failedClusters++
+		logging.Log.Errorf("Cluster %s has incomplete data: %s", cluster.Name, strings.Join(cluster.Errors, "; "))
+	}
+	if failedClusters > 0 {
+		logging.Log.Warnf("%d of %d clusters have incomplete data", failedClusters, len(clusters))
+	}
+	return failedClusters
 }
 
 func mergeClusterInfoWithRuntime(clusters []model.ClusterWithAgentMetadata, runtimeClusters map[string]model.RuntimeCluster) {
@@ -73,30 +326,85 @@ func mergeClusterInfoWithRuntime(clusters []model.ClusterWithAgentMetadata, runt
 		runtimeCluster, exist := runtimeClusters[cluster.Name]
 		if exist {
 			cluster.RuntimeEnabled = runtimeCluster.IsEnabled
+			cluster.RuntimeResults = strconv.Itoa(runtimeCluster.ResultCount)
+			if runtimeCluster.Complete {
+				cluster.RuntimeWorkloads = strconv.Itoa(runtimeCluster.WorkloadCount())
+				cluster.RuntimeVulnerabilities = strconv.Itoa(runtimeCluster.VulnerabilityCount())
+				posture := runtimeCluster.VulnerabilityPosture()
+				cluster.VulnerabilityPosture = &posture
+				cluster.Workloads = runtimeCluster.Workloads()
+			}
 		} else {
 			cluster.RuntimeEnabled = false
 		}
 	}
 }
 
-func getMetricsData(clusterWithAgentMetadata []model.ClusterWithAgentMetadata) {
+func classifyClusters(clusters []model.ClusterWithAgentMetadata, rules *classifier.Rules) {
+	for i := range clusters {
+		clusters[i].Dimensions = rules.Classify(clusters[i].ClusterInfo)
+	}
+}
 
-	totalNodesConnected := 0
-	totalNodes := 0
+// evaluateVersionCompliance checks the agent versions of every cluster against the policy.
+func evaluateVersionCompliance(clusters []model.ClusterWithAgentMetadata, versionPolicy *version.Policy) {
+	for i := range clusters {
+		cluster := &clusters[i]
+		if versionPolicy == nil || len(cluster.AgentVersions) == 0 {
+			cluster.VersionCompliance = version.NotEvaluated
+			continue
+		}
+		compliant, reasons := versionPolicy.Evaluate(cluster.AgentVersions)
+		cluster.VersionCompliance = version.NonCompliant
+		if compliant {
+			cluster.VersionCompliance = version.Compliant
+		}
+		cluster.VersionComplianceReasons = reasons
+	}
+}
 
-	for _, cluster := range clusterWithAgentMetadata {
-		nodesConnected, err := strconv.Atoi(cluster.NodesConnected)
-		if err != nil {
-			logging.Log.Fatal("error converting NodesConnected to int: ", err)
-			return
+// detectStaleAgents computes the time since every agent last reported and marks as stale the agents,
+// and the clusters, that did not report within the window. A window of 0 only computes the times.
+func detectStaleAgents(clusters []model.ClusterWithAgentMetadata, now time.Time, window time.Duration) {
+	for i := range clusters {
+		cluster := &clusters[i]
+		for j := range cluster.AgentDetails {
+			detail := &cluster.AgentDetails[j]
+			lastSeen, err := detail.LastSeen()
+			if err != nil {
+				logging.Log.Debugf("Ignoring last seen %q of an agent of cluster %s: %v", detail.AgentLastSeen, cluster.Name, err)
+				continue
+			}
+			detail.SinceLastSeen = now.Sub(lastSeen)
+			if window > 0 && detail.SinceLastSeen > window {
+				detail.Stale = true
+				cluster.StaleAgents++
+			}
+			if lastSeen.After(cluster.LastSeen) {
+				cluster.LastSeen = lastSeen
+			}
+		}
+		if cluster.LastSeen.IsZero() {
+			continue
 		}
-		totalNodesConnected += nodesConnected
-		totalNodes += cluster.NodeCount
+		cluster.SinceLastSeen = now.Sub(cluster.LastSeen)
+		if window > 0 && cluster.SinceLastSeen > window {
+			cluster.Stale = true
+		}
+	}
+}
+
+func getMetricsData(clusterWithAgentMetadata []model.ClusterWithAgentMetadata) {
+
+	metrics, err := model.ComputeMetrics(clusterWithAgentMetadata)
+	if err != nil {
+		logging.Log.Fatal("error computing metrics: ", err)
+		return
 	}
 
-	logging.Log.Info("Total Nodes Connected: ", totalNodesConnected)
-	logging.Log.Info("Total Nodes: ", totalNodes)
-	logging.Log.Info("Percentage of Nodes Connected: ", float64(totalNodesConnected)/float64(totalNodes)*100)
+	logging.Log.Info("Total Nodes Connected: ", metrics.TotalNodesConnected)
+	logging.Log.Info("Total Nodes: ", metrics.TotalNodes)
+	logging.Log.Info("Percentage of Nodes Connected: ", metrics.NodeCoverage())
 }
 
 func updateClusterMetadataWithAgentData(clusterMetadata *model.ClusterWithAgentMetadata, agentData model.AgentData) {
@@ -113,61 +421,112 @@ func updateClusterMetadataWithAgentData(clusterMetadata *model.ClusterWithAgentM
 		clusterMetadata.AgentStatus = agentDetails[0].AgentStatus
 		clusterMetadata.AgentVersion = agentDetails[0].AgentVersion
 	}
+	clusterMetadata.AgentDetails = agentData.Details
+	clusterMetadata.AgentStatusCounts = countAgentStatuses(agentData.Details)
+	clusterMetadata.AgentVersions = distinctAgentVersions(agentDetails)
 }
 
-func getExtraFeaturesInformationFromClusters(clusters []model.ClusterInfo, sysdigClient client.API) ([]model.ClusterWithAgentMetadata, map[string]model.RuntimeCluster, error) {
+func countAgentStatuses(agentDetails []model.AgentDetail) map[string]int {
+	counts := make(map[string]int)
+	for _, detail := range agentDetails {
+		counts[detail.AgentStatus]++
+	}
+	return counts
+}
+
+// distinctAgentVersions returns the sorted agent versions reported by the nodes, ignoring empty ones.
+func distinctAgentVersions(agentDetails []model.AgentDetail) []string {
+	seen := make(map[string]bool)
+	var versions []string
+	for _, detail := range agentDetails {
+		if detail.AgentVersion == "" || seen[detail.AgentVersion] {
+			continue
+		}
+		seen[detail.AgentVersion] = true
+		versions = append(versions, detail.AgentVersion)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+func getExtraFeaturesInformationFromClusters(ctx context.Context, clusters []model.ClusterInfo, sysdigClient client.API, runtimeDetails bool, concurrency int, partialResults bool) ([]model.ClusterWithAgentMetadata, map[string]model.RuntimeCluster, error) {
 	clustersWithAgentMetadata := make([]model.ClusterWithAgentMetadata, len(clusters))
 
 	// map of cluster name to runtime data
 	runtimeClusters := make(map[string]model.RuntimeCluster)
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(clusters))
+	failureChan := make(chan enrichmentFailure, 2*len(clusters))
+
+	// Cap in-flight requests across agent and runtime lookups
+	pool := worker.NewPool(concurrency, map[string]int{
+		agentTask:   config.Config.AgentConcurrency,
+		runtimeTask: config.Config.RuntimeConcurrency,
+	})
 
 	for i, cluster := range clusters {
-		wg.Add(2)
-		go func(i int, cluster model.ClusterInfo) {
-			defer wg.Done()
-
-			clusterMetadata := model.ClusterWithAgentMetadata{
-				ClusterInfo:    cluster,
-				NodesConnected: "0",
-				AgentStatus:    "N/A",
-				AgentVersion:   "N/A",
-				RuntimeEnabled: false,
+		i, cluster := i, cluster
+
+		// Defaults are set upfront so clusters skipped after a cancellation are still reported
+		clustersWithAgentMetadata[i] = model.ClusterWithAgentMetadata{
+			ClusterInfo:    cluster,
+			NodesConnected: "0",
+			AgentStatus:    "N/A",
+			AgentVersion:   "N/A",
+			RuntimeEnabled: false,
+
+			RuntimeResults:         "0",
+			RuntimeWorkloads:       "N/A",
+			RuntimeVulnerabilities: "N/A",
+		}
+
+		pool.Submit(agentTask, func() {
+			if !cluster.AgentConnected {
+				return
+			}
+			if ctx.Err() != nil {
+				failureChan <- enrichmentFailure{index: i, lookup: agentTask, err: ctx.Err()}
+				return
 			}
 
-			if cluster.AgentConnected {
-				agentData, err := sysdigClient.GetAgentData(cluster.Name)
-				if err != nil {
-					errChan <- fmt.Errorf("failed to get agent data for cluster %v. Error: %v", cluster.Name, err)
-					return
-				}
+			agentData, err := sysdigClient.GetAgentData(ctx, cluster.Name)
+			if err != nil {
+				failureChan <- enrichmentFailure{index: i, lookup: agentTask, err: fmt.Errorf("failed to get agent data for cluster %v. Error: %w", cluster.Name, err)}
+				return
+			}
 
-				updateClusterMetadataWithAgentData(&clusterMetadata, agentData)
+			updateClusterMetadataWithAgentData(&clustersWithAgentMetadata[i], agentData)
+		})
+		pool.Submit(runtimeTask, func() {
+			if ctx.Err() != nil {
+				failureChan <- enrichmentFailure{index: i, lookup: runtimeTask, err: ctx.Err()}
+				return
 			}
 
-			clustersWithAgentMetadata[i] = clusterMetadata
-		}(i, cluster)
-		go func(i int, cluster model.ClusterInfo) {
-			defer wg.Done()
-			runtimeCluster, err := sysdigClient.GetRuntimeData(cluster.Name)
+			runtimeCluster, err := sysdigClient.GetRuntimeData(ctx, cluster.Name, runtimeDetails)
 			if err != nil {
-				errChan <- fmt.Errorf("failed to get runtime data for cluster %v: %v", cluster.Name, err)
+				failureChan <- enrichmentFailure{index: i, lookup: runtimeTask, err: fmt.Errorf("failed to get runtime data for cluster %v: %w", cluster.Name, err)}
 				return
 			}
 			mutex.Lock()
 			runtimeClusters[cluster.Name] = runtimeCluster
 			mutex.Unlock()
-		}(i, cluster)
+		})
 	}
 
-	wg.Wait()
-	close(errChan)
+	pool.Wait()
+	close(failureChan)
 
-	for err := range errChan {
-		if err != nil {
-			return nil, nil, err
+	for failure := range failureChan {
+		cluster := &clustersWithAgentMetadata[failure.index]
+		// Lookups skipped or aborted by a cancellation are not failures, partial results are kept
+		// and the incomplete clusters are marked as such
+		if errors.Is(failure.err, context.Canceled) {
+			cluster.Errors = append(cluster.Errors, fmt.Sprintf("%s lookup interrupted", failure.lookup))
+			continue
+		}
+		if !partialResults {
+			return nil, nil, failure.err
 		}
+		cluster.Errors = append(cluster.Errors, failure.err.Error())
 	}
 	return clustersWithAgentMetadata, runtimeClusters, nil
 }
@@ -199,35 +558,59 @@ func filterAgentDetails(details []model.AgentDetail, statuses []model.AgentStatu
 }
 
 type CommandLineArgs struct {
-	Limit     int
-	Filter    string
-	Connected string
-	Output    string
+	Limit             int
+	Filter            string
+	Connected         string
+	Output            string
+	NodesOutput       string
+	WorkloadsOutput   string
+	RuntimeDetails    bool
+	Concurrency       int
+	PartialResults    bool
+	Format            string
+	RulesFile         string
+	VersionPolicyFile string
+	StaleAfter        time.Duration
+	Listen            string
+	Interval          time.Duration
+	SnapshotDB        string
+	FromSnapshot      int64
+	Thresholds        gate.Thresholds
 }
 
-func customUsage() {
-	fmt.Fprintf(flag.CommandLine.Output(), "Description:\n")
-	fmt.Fprintf(flag.CommandLine.Output(), "\tcommand-line tool for tracking cluster onboarding based on CSPM data. It provides functionalities like fetching cluster data, filtering based on specific criteria, and exporting details to a CSV file.\n\n")
-	fmt.Fprintf(flag.CommandLine.Output(), "Requirements:\n")
-	fmt.Fprintf(flag.CommandLine.Output(), "\tSet SYSDIG_TOKEN with your Secure API token from Sysdig UI.\n\n")
-	fmt.Fprintf(flag.CommandLine.Output(), "\tSet SYSDIG_URL with your Sysdig API endpoint URL.\n\n")
-	fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
-	flag.PrintDefaults()
+func customUsage(flagSet *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(flagSet.Output(), "Description:\n")
+		fmt.Fprintf(flagSet.Output(), "\tcommand-line tool for tracking cluster onboarding based on CSPM data. It provides functionalities like fetching cluster data, filtering based on specific criteria, and exporting details to a CSV file.\n\n")
+		fmt.Fprintf(flagSet.Output(), "Requirements:\n")
+		fmt.Fprintf(flagSet.Output(), "\tSet SYSDIG_TOKEN with your Secure API token from Sysdig UI.\n\n")
+		fmt.Fprintf(flagSet.Output(), "\tSet SYSDIG_URL with your Sysdig API endpoint URL.\n\n")
+		fmt.Fprintf(flagSet.Output(), "Commands:\n")
+		fmt.Fprintf(flagSet.Output(), "\t(default)\tCollect the cluster data once and write it to the output file.\n")
+		fmt.Fprintf(flagSet.Output(), "\t%s\t\tRun as a daemon refreshing the data on a schedule and serving it on /metrics, /clusters.json and /clusters.csv.\n", serveCommand)
+		fmt.Fprintf(flagSet.Output(), "\t%s\t\tCompare two previous runs, given as report files or snapshot IDs.\n", diffCommand)
+		fmt.Fprintf(flagSet.Output(), "\t%s\t\tShow how onboarding evolved across the stored snapshots.\n\n", trendCommand)
+		fmt.Fprintf(flagSet.Output(), "Usage of %s:\n", flagSet.Name())
+		flagSet.PrintDefaults()
+	}
 }
 
-func parseArguments() CommandLineArgs {
-	flag.Usage = customUsage
-
-	limit := flag.Int("limit", 150, "Limit the number of results")
-	filter := flag.String("filter", "", "Filter criteria")
-	connected := flag.String("connected", "", "Connected status filter")
-	output := flag.String("output", "clusters.csv", "Output file name")
-	flag.Parse()
-
-	return CommandLineArgs{
-		Limit:     *limit,
-		Filter:    *filter,
-		Connected: *connected,
-		Output:    *output,
-	}
+// newFlagSet registers the flags shared by every command collecting cluster data.
+func newFlagSet(name string) (*flag.FlagSet, *CommandLineArgs) {
+	args := &CommandLineArgs{Thresholds: gate.NewThresholds()}
+	flagSet := flag.NewFlagSet(name, flag.ExitOnError)
+	flagSet.Usage = customUsage(flagSet)
+
+	flagSet.IntVar(&args.Limit, "limit", 0, "Maximum number of clusters to fetch (0 means all clusters)")
+	flagSet.StringVar(&args.Filter, "filter", "", "Filter criteria")
+	flagSet.StringVar(&args.Connected, "connected", "", "Connected status filter")
+	flagSet.BoolVar(&args.RuntimeDetails, "runtime-details", false, "Fetch every runtime result page to compute workload and vulnerability counts per cluster")
+	flagSet.IntVar(&args.Concurrency, "concurrency", config.Config.Concurrency, "Maximum number of concurrent agent and runtime lookups")
+	flagSet.BoolVar(&args.PartialResults, "partial-results", false, "Keep going when a cluster lookup fails, recording the error in the report and exiting with code 2")
+	flagSet.StringVar(&args.RulesFile, "rules", config.Config.ClassificationRulesFile, "JSON file with environment and custom dimension classification rules")
+	flagSet.StringVar(&args.VersionPolicyFile, "version-policy", config.Config.AgentVersionPolicyFile, "JSON file with the supported agent versions (min_version, allowed_majors, eol)")
+	flagSet.DurationVar(&args.StaleAfter, "stale-after", time.Duration(config.Config.StaleAgentWindow)*time.Minute, "Agents not reporting for longer than this are stale (0 disables stale detection)")
+	flagSet.StringVar(&args.SnapshotDB, "snapshot-db", config.Config.SnapshotDB, "SQLite database where every run is stored as a snapshot")
+
+	return flagSet, args
 }
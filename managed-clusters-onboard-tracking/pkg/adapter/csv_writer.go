@@ -1,60 +1,33 @@
 package adapter
 
 import (
-	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/logging"
 	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
 	"encoding/csv"
-	"fmt"
-	"os"
-	"strconv"
+	"io"
 )
 
-func WriteToCSV(fileName string, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
-	file, err := os.Create(fileName)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+// CSVWriter writes one row per cluster preceded by a header.
+type CSVWriter struct{}
+
+func (CSVWriter) Write(w io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	writer := csv.NewWriter(w)
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	// Vulnerability posture and custom dimensions are appended after the fixed columns
+	columns := optionalColumns(clusterWithAgentMetadata)
 
 	// Write header
-	writer.Write([]string{"name", "node_count", "agentConnected", "nodes_connected", "agent_status", "agent_version", "provider", "environment", "runtime_enabled"})
+	writer.Write(append(append([]string{}, csvHeader...), columns...))
 
 	// Write data
 	for _, clustersWithAgentMetadata := range clusterWithAgentMetadata {
-		// Use the fields from `clustersWithAgentMetadata`, e.g., clustersWithAgentMetadata.Name, clustersWithAgentMetadata.NodeCount, etc.
-		writer.Write([]string{
-			clustersWithAgentMetadata.Name,
-			fmt.Sprintf("%d", clustersWithAgentMetadata.NodeCount),
-			fmt.Sprintf("%v", clustersWithAgentMetadata.AgentConnected),
-			clustersWithAgentMetadata.NodesConnected,
-			clustersWithAgentMetadata.AgentStatus,
-			clustersWithAgentMetadata.AgentVersion,
-			clustersWithAgentMetadata.Provider,
-			getEnvironment(string(clustersWithAgentMetadata.Name[3])),
-			strconv.FormatBool(clustersWithAgentMetadata.RuntimeEnabled),
-		})
+		record := newClusterRecord(clustersWithAgentMetadata)
+		writer.Write(append(record.csvRow(), record.optionalValues(columns)...))
 	}
-	dir, err := os.Getwd()
-	if err != nil {
-		logging.Log.Errorf("Failed to obtain current dir. Error %s", err)
-	}
-	logging.Log.Debugf("Created csv file successfully. File name: %s, file path: %s", fileName, dir)
 
-	return nil
+	writer.Flush()
+	return writer.Error()
 }
 
-func getEnvironment(environment string) string {
-	switch environment {
-	case "d":
-		return "development"
-	case "p":
-		return "production"
-	case "i":
-		return "pre-production"
-	default:
-		return "unknown"
-	}
+func WriteToCSV(fileName string, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	return WriteToFile(fileName, CSVWriter{}, clusterWithAgentMetadata)
 }
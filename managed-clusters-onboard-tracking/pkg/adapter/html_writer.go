@@ -0,0 +1,97 @@
+package adapter
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	_ "embed"
+	"html/template"
+	"io"
+	"time"
+)
+
+//go:embed templates/report.html.tmpl
+var htmlReportTemplate string
+
+var htmlTemplate = template.Must(template.New("report").Parse(htmlReportTemplate))
+
+// htmlReport is the data rendered by the HTML dashboard template.
+type htmlReport struct {
+	GeneratedAt    string
+	Metrics        model.Metrics
+	Charts         []htmlChart
+	Header         []string
+	Rows           []htmlRow
+	WithoutAgent   []ClusterRecord
+	WithoutRuntime []ClusterRecord
+}
+
+type htmlChart struct {
+	Title  string
+	Groups []model.GroupMetrics
+}
+
+type htmlRow struct {
+	Class  string
+	Values []string
+}
+
+// HTMLWriter writes a self-contained HTML dashboard, with styles and scripts inlined so the file can be shared as is.
+type HTMLWriter struct{}
+
+func (HTMLWriter) Write(w io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	metrics, err := model.ComputeMetrics(clusterWithAgentMetadata)
+	if err != nil {
+		return err
+	}
+
+	columns := optionalColumns(clusterWithAgentMetadata)
+	report := htmlReport{
+		GeneratedAt: time.Now().UTC().Format(time.RFC1123),
+		Metrics:     metrics,
+		Header:      append(append([]string{}, csvHeader...), columns...),
+	}
+
+	for _, breakdown := range breakdowns {
+		if breakdown.column != "provider" && breakdown.column != "environment" {
+			continue
+		}
+		groups, err := model.ComputeGroupMetrics(clusterWithAgentMetadata, breakdown.key)
+		if err != nil {
+			return err
+		}
+		report.Charts = append(report.Charts, htmlChart{Title: breakdown.column, Groups: groups})
+	}
+
+	for _, cluster := range clusterWithAgentMetadata {
+		record := newClusterRecord(cluster)
+		values := append(record.csvRow(), record.optionalValues(columns)...)
+		report.Rows = append(report.Rows, htmlRow{Class: rowClass(record), Values: values})
+
+		// Incomplete clusters keep defaults in place of the data of their failed lookups
+		if cluster.Incomplete() {
+			continue
+		}
+		if !record.AgentConnected {
+			report.WithoutAgent = append(report.WithoutAgent, record)
+		}
+		if !record.RuntimeEnabled {
+			report.WithoutRuntime = append(report.WithoutRuntime, record)
+		}
+	}
+
+	return htmlTemplate.Execute(w, report)
+}
+
+// rowClass returns the CSS class highlighting disconnected, out of date and stale agents.
+func rowClass(record ClusterRecord) string {
+	switch model.AgentStatusType(record.AgentStatus) {
+	case model.AgentStatusDisconnected:
+		return "disconnected"
+	case model.AgentStatusOutOfDate:
+		return "out-of-date"
+	default:
+		if record.Stale {
+			return "stale"
+		}
+		return ""
+	}
+}
@@ -0,0 +1,34 @@
+package adapter
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"encoding/json"
+	"io"
+)
+
+// JSONWriter writes the clusters as a single indented JSON array.
+type JSONWriter struct{}
+
+func (JSONWriter) Write(w io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	records := make([]ClusterRecord, 0, len(clusterWithAgentMetadata))
+	for _, cluster := range clusterWithAgentMetadata {
+		records = append(records, newClusterRecord(cluster))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// NDJSONWriter writes one JSON object per line, one line per cluster.
+type NDJSONWriter struct{}
+
+func (NDJSONWriter) Write(w io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	encoder := json.NewEncoder(w)
+	for _, cluster := range clusterWithAgentMetadata {
+		if err := encoder.Encode(newClusterRecord(cluster)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package adapter
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/version"
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Number of clusters listed in the most exposed clusters section
+const mostExposedClusters = 10
+
+// MarkdownWriter writes a summary suited for wiki pages and pull request comments.
+type MarkdownWriter struct{}
+
+func (MarkdownWriter) Write(w io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	metrics, err := model.ComputeMetrics(clusterWithAgentMetadata)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "# Managed Clusters Onboarding\n\n")
+	fmt.Fprintf(out, "| Metric | Value |\n|---|---|\n")
+	fmt.Fprintf(out, "| Percentage of Nodes Connected | %.1f%% |\n", metrics.NodeCoverage())
+	fmt.Fprintf(out, "| Total Nodes Connected | %d / %d |\n", metrics.TotalNodesConnected, metrics.TotalNodes)
+	fmt.Fprintf(out, "| Clusters With Agent Connected | %d / %d |\n", metrics.ClustersAgentConnected, metrics.Clusters)
+	fmt.Fprintf(out, "| Clusters With Runtime Enabled | %d / %d (%.1f%%) |\n", metrics.ClustersRuntimeEnabled, metrics.Clusters, metrics.RuntimeCoverage())
+	if metrics.ClustersIncomplete > 0 {
+		fmt.Fprintf(out, "| Clusters With Incomplete Data | %d |\n", metrics.ClustersIncomplete)
+	}
+	fmt.Fprintln(out)
+
+	writeNotOnboardedClusters(out, clusterWithAgentMetadata)
+	writeAgentVersionDistribution(out, clusterWithAgentMetadata)
+	writeVersionCompliance(out, clusterWithAgentMetadata)
+	writeStaleClusters(out, clusterWithAgentMetadata)
+	writeMostExposedClusters(out, clusterWithAgentMetadata)
+	writeWorkloadTypeCoverage(out, clusterWithAgentMetadata)
+
+	return out.Flush()
+}
+
+// writeNotOnboardedClusters lists clusters without a connected agent grouped by provider and environment.
+func writeNotOnboardedClusters(out io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) {
+	groups := make(map[string][]model.ClusterWithAgentMetadata)
+	for _, cluster := range clusterWithAgentMetadata {
+		if cluster.AgentConnected || cluster.Incomplete() {
+			continue
+		}
+		key := fmt.Sprintf("%s / %s", valueOrUnknown(cluster.Provider), environmentOf(cluster))
+		groups[key] = append(groups[key], cluster)
+	}
+
+	fmt.Fprintf(out, "## Clusters Not Onboarded\n\n")
+	if len(groups) == 0 {
+		fmt.Fprintf(out, "All clusters have an agent connected.\n\n")
+		return
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(out, "### %s (%d)\n\n", escapeMarkdown(key), len(groups[key]))
+		fmt.Fprintf(out, "| Cluster | Region | Nodes | Runtime Enabled |\n|---|---|---|---|\n")
+		for _, cluster := range groups[key] {
+			fmt.Fprintf(out, "| %s | %s | %d | %t |\n", escapeMarkdown(cluster.Name), escapeMarkdown(cluster.Region), cluster.NodeCount, cluster.RuntimeEnabled)
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+// writeAgentVersionDistribution counts clusters and connected nodes per agent version.
+func writeAgentVersionDistribution(out io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) {
+	fmt.Fprintf(out, "## Agent Version Distribution\n\n")
+
+	groups, err := model.ComputeGroupMetrics(clusterWithAgentMetadata, func(c model.ClusterWithAgentMetadata) string { return c.AgentVersion })
+	if err != nil || len(groups) == 0 {
+		fmt.Fprintf(out, "No agent version data.\n")
+		return
+	}
+
+	fmt.Fprintf(out, "| Agent Version | Clusters | Nodes Connected |\n|---|---|---|\n")
+	for _, group := range groups {
+		fmt.Fprintf(out, "| %s | %d | %d |\n", escapeMarkdown(valueOrUnknown(group.Key)), group.Clusters, group.TotalNodesConnected)
+	}
+}
+
+// writeVersionCompliance lists clusters breaking the agent version policy or running mixed versions.
+func writeVersionCompliance(out io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) {
+	var rows []string
+	for _, cluster := range clusterWithAgentMetadata {
+		record := newClusterRecord(cluster)
+		if record.VersionCompliance != version.NonCompliant && !record.MixedAgentVersions {
+			continue
+		}
+		rows = append(rows, fmt.Sprintf("| %s | %s | %s | %t | %s |", escapeMarkdown(record.Name), escapeMarkdown(strings.Join(record.AgentVersions, ", ")),
+			record.VersionCompliance, record.MixedAgentVersions, escapeMarkdown(strings.Join(record.ComplianceReasons, "; "))))
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "\n## Agent Version Compliance\n\n")
+	fmt.Fprintf(out, "| Cluster | Agent Versions | Compliance | Mixed Versions | Reasons |\n|---|---|---|---|---|\n")
+	for _, row := range rows {
+		fmt.Fprintln(out, row)
+	}
+}
+
+// writeStaleClusters lists clusters whose agents stopped reporting within the stale window.
+func writeStaleClusters(out io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) {
+	var stale []ClusterRecord
+	for _, cluster := range clusterWithAgentMetadata {
+		if cluster.Stale {
+			stale = append(stale, newClusterRecord(cluster))
+		}
+	}
+	if len(stale) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "\n## Stale Agents\n\n")
+	fmt.Fprintf(out, "| Cluster | Agent Last Seen | Seconds Since Last Seen | Stale Agents |\n|---|---|---|---|\n")
+	for _, record := range stale {
+		fmt.Fprintf(out, "| %s | %s | %s | %d |\n", escapeMarkdown(record.Name), record.AgentLastSeen, record.SecondsSinceLastSeen, record.AgentsStale)
+	}
+}
+
+// writeMostExposedClusters lists the clusters with the most critical and high vulnerabilities,
+// only when runtime details were collected.
+func writeMostExposedClusters(out io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) {
+	var exposed []model.ClusterWithAgentMetadata
+	for _, cluster := range clusterWithAgentMetadata {
+		if cluster.VulnerabilityPosture != nil {
+			exposed = append(exposed, cluster)
+		}
+	}
+	if len(exposed) == 0 {
+		return
+	}
+	sort.SliceStable(exposed, func(i, j int) bool {
+		a, b := exposed[i].VulnerabilityPosture, exposed[j].VulnerabilityPosture
+		if a.Critical != b.Critical {
+			return a.Critical > b.Critical
+		}
+		return a.High > b.High
+	})
+	if len(exposed) > mostExposedClusters {
+		exposed = exposed[:mostExposedClusters]
+	}
+
+	fmt.Fprintf(out, "\n## Most Exposed Clusters\n\n")
+	fmt.Fprintf(out, "| Cluster | Critical | High | Running | Exploitable | Policy Failed | Accepted Risk |\n|---|---|---|---|---|---|---|\n")
+	for _, cluster := range exposed {
+		posture := cluster.VulnerabilityPosture
+		fmt.Fprintf(out, "| %s | %d | %d | %d | %d | %d | %d |\n", escapeMarkdown(cluster.Name), posture.Critical, posture.High,
+			posture.Running, posture.Exploitable, posture.PolicyFailed, posture.AcceptedRisk)
+	}
+}
+
+// writeWorkloadTypeCoverage counts the workloads with runtime results per workload type,
+// only when runtime details were collected.
+func writeWorkloadTypeCoverage(out io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) {
+	workloads := make(map[string]int)
+	clusters := make(map[string]int)
+	for _, cluster := range clusterWithAgentMetadata {
+		for workloadType, count := range cluster.WorkloadTypeCounts() {
+			workloadType = valueOrUnknown(workloadType)
+			workloads[workloadType] += count
+			clusters[workloadType]++
+		}
+	}
+	if len(workloads) == 0 {
+		return
+	}
+
+	workloadTypes := make([]string, 0, len(workloads))
+	for workloadType := range workloads {
+		workloadTypes = append(workloadTypes, workloadType)
+	}
+	sort.Strings(workloadTypes)
+
+	fmt.Fprintf(out, "\n## Runtime Coverage by Workload Type\n\n")
+	fmt.Fprintf(out, "| Workload Type | Workloads With Runtime Results | Clusters |\n|---|---|---|\n")
+	for _, workloadType := range workloadTypes {
+		fmt.Fprintf(out, "| %s | %d | %d |\n", escapeMarkdown(workloadType), workloads[workloadType], clusters[workloadType])
+	}
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
+
+// escapeMarkdown prevents values from breaking table cells.
+func escapeMarkdown(value string) string {
+	return strings.ReplaceAll(value, "|", "\\|")
+}
@@ -0,0 +1,126 @@
+package adapter
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// NodeRecord is the flat representation of a single agent record of a cluster.
+type NodeRecord struct {
+	Cluster        string `json:"cluster"`
+	Provider       string `json:"provider"`
+	Environment    string `json:"environment"`
+	AgentStatus    string `json:"agent_status"`
+	AgentVersion   string `json:"agent_version"`
+	AgentLastSeen  string `json:"agent_last_seen"`
+	DeploymentType string `json:"deployment_type"`
+	Containerised  bool   `json:"containerised"`
+	// SecondsSinceLastSeen is N/A when the last seen time could not be parsed
+	SecondsSinceLastSeen string `json:"seconds_since_last_seen"`
+	Stale                bool   `json:"stale"`
+}
+
+var nodeCSVHeader = []string{"cluster", "provider", "environment", "agent_status", "agent_version", "agent_last_seen", "deployment_type", "containerised", "seconds_since_last_seen", "stale"}
+
+// newNodeRecords returns one record per agent reported by the clusters, in cluster order.
+func newNodeRecords(clusterWithAgentMetadata []model.ClusterWithAgentMetadata) []NodeRecord {
+	var records []NodeRecord
+	for _, cluster := range clusterWithAgentMetadata {
+		environment := environmentOf(cluster)
+		for _, detail := range cluster.AgentDetails {
+			secondsSinceLastSeen := "N/A"
+			if _, err := detail.LastSeen(); err == nil {
+				secondsSinceLastSeen = strconv.Itoa(int(detail.SinceLastSeen.Seconds()))
+			}
+			records = append(records, NodeRecord{
+				Cluster:        cluster.Name,
+				Provider:       cluster.Provider,
+				Environment:    environment,
+				AgentStatus:    detail.AgentStatus,
+				AgentVersion:   detail.AgentVersion,
+				AgentLastSeen:  detail.AgentLastSeen,
+				DeploymentType: detail.DeploymentType,
+				Containerised:  detail.Containerised,
+
+				SecondsSinceLastSeen: secondsSinceLastSeen,
+				Stale:                detail.Stale,
+			})
+		}
+	}
+	return records
+}
+
+// csvRow returns the record values in nodeCSVHeader order.
+func (r NodeRecord) csvRow() []string {
+	return []string{
+		r.Cluster,
+		r.Provider,
+		r.Environment,
+		r.AgentStatus,
+		r.AgentVersion,
+		r.AgentLastSeen,
+		r.DeploymentType,
+		strconv.FormatBool(r.Containerised),
+		r.SecondsSinceLastSeen,
+		strconv.FormatBool(r.Stale),
+	}
+}
+
+// NewNodeWriter returns the Writer of the node-level report for the given format.
+func NewNodeWriter(format string) (Writer, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return NodeCSVWriter{}, nil
+	case FormatJSON:
+		return NodeJSONWriter{}, nil
+	case FormatNDJSON:
+		return NodeNDJSONWriter{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported node report format %q", format)
+	}
+}
+
+// NodeCSVWriter writes one row per agent preceded by a header.
+type NodeCSVWriter struct{}
+
+func (NodeCSVWriter) Write(w io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	writer := csv.NewWriter(w)
+	writer.Write(nodeCSVHeader)
+	for _, record := range newNodeRecords(clusterWithAgentMetadata) {
+		writer.Write(record.csvRow())
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// NodeJSONWriter writes the agents as a single indented JSON array.
+type NodeJSONWriter struct{}
+
+func (NodeJSONWriter) Write(w io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	records := newNodeRecords(clusterWithAgentMetadata)
+	if records == nil {
+		records = []NodeRecord{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// NodeNDJSONWriter writes one JSON object per line, one line per agent.
+type NodeNDJSONWriter struct{}
+
+func (NodeNDJSONWriter) Write(w io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	encoder := json.NewEncoder(w)
+	for _, record := range newNodeRecords(clusterWithAgentMetadata) {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
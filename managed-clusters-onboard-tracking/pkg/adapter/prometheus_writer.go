@@ -0,0 +1,206 @@
+package adapter
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const metricPrefix = "sysdig_onboarding_"
+
+// PrometheusWriter writes the onboarding metrics in the Prometheus text exposition format,
+// suitable for the node_exporter textfile collector and the /metrics endpoint.
+type PrometheusWriter struct{}
+
+func (PrometheusWriter) Write(w io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	metrics, err := model.ComputeMetrics(clusterWithAgentMetadata)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(w)
+
+	writeMetricHeader(out, "clusters", "Number of clusters in the datasource with complete data.")
+	writeSample(out, "clusters", nil, float64(metrics.Clusters))
+	writeMetricHeader(out, "clusters_incomplete", "Number of clusters whose lookups failed, left out of every other metric.")
+	writeSample(out, "clusters_incomplete", nil, float64(metrics.ClustersIncomplete))
+	writeMetricHeader(out, "nodes", "Total number of nodes across all clusters.")
+	writeSample(out, "nodes", nil, float64(metrics.TotalNodes))
+	writeMetricHeader(out, "nodes_connected", "Total number of nodes with a connected agent.")
+	writeSample(out, "nodes_connected", nil, float64(metrics.TotalNodesConnected))
+	writeMetricHeader(out, "node_coverage_ratio", "Ratio of nodes with a connected agent.")
+	writeSample(out, "node_coverage_ratio", nil, metrics.NodeCoverage()/100)
+
+	// Incomplete clusters only have the cluster_incomplete series, their defaults are not actual data
+	var records []clusterSeries
+	statusCounts := make(map[string]int)
+	writeMetricHeader(out, "cluster_incomplete", "Whether a lookup of the cluster failed.")
+	for _, cluster := range clusterWithAgentMetadata {
+		record := newClusterSeries(cluster)
+		writeSample(out, "cluster_incomplete", record.clusterLabels(), boolToFloat(cluster.Incomplete()))
+		if cluster.Incomplete() {
+			continue
+		}
+		records = append(records, record)
+		statusCounts[cluster.AgentStatus]++
+	}
+	statuses := make([]string, 0, len(statusCounts))
+	for status := range statusCounts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	writeMetricHeader(out, "clusters_by_agent_status", "Number of clusters per agent status.")
+	for _, status := range statuses {
+		writeSample(out, "clusters_by_agent_status", [][2]string{{"status", status}}, float64(statusCounts[status]))
+	}
+
+	clusterMetrics := []struct {
+		name  string
+		help  string
+		value func(ClusterRecord) float64
+	}{
+		{"cluster_nodes", "Number of nodes of the cluster.", func(r ClusterRecord) float64 { return float64(r.NodeCount) }},
+		{"cluster_nodes_connected", "Number of nodes of the cluster with a connected agent.", func(r ClusterRecord) float64 { return atof(r.NodesConnected) }},
+		{"cluster_connected_ratio", "Ratio of nodes of the cluster with a connected agent.", func(r ClusterRecord) float64 {
+			if r.NodeCount == 0 {
+				return 0
+			}
+			return atof(r.NodesConnected) / float64(r.NodeCount)
+		}},
+		{"cluster_runtime_enabled", "Whether runtime scanning results exist for the cluster.", func(r ClusterRecord) float64 { return boolToFloat(r.RuntimeEnabled) }},
+		{"cluster_stale", "Whether no agent of the cluster reported within the stale window.", func(r ClusterRecord) float64 { return boolToFloat(r.Stale) }},
+		{"cluster_stale_agents", "Number of agents of the cluster that did not report within the stale window.", func(r ClusterRecord) float64 { return float64(r.AgentsStale) }},
+		{"cluster_mixed_agent_versions", "Whether nodes of the cluster run different agent versions.", func(r ClusterRecord) float64 { return boolToFloat(r.MixedAgentVersions) }},
+	}
+
+	for _, metric := range clusterMetrics {
+		writeMetricHeader(out, metric.name, metric.help)
+		for _, record := range records {
+			writeSample(out, metric.name, record.clusterLabels(), metric.value(record.ClusterRecord))
+		}
+	}
+
+	// Clusters whose agents never reported a valid last seen time have no sample
+	writeMetricHeader(out, "cluster_seconds_since_last_seen", "Seconds since any agent of the cluster last reported.")
+	for _, record := range records {
+		if record.AgentLastSeen != "" {
+			writeSample(out, "cluster_seconds_since_last_seen", record.clusterLabels(), atof(record.SecondsSinceLastSeen))
+		}
+	}
+
+	// Vulnerability posture is only exported for clusters with runtime details
+	for _, postureColumn := range postureColumns {
+		name := "cluster_" + postureColumn.name
+		writeMetricHeader(out, name, postureColumn.help)
+		for _, record := range records {
+			if record.VulnerabilityPosture != nil {
+				writeSample(out, name, record.clusterLabels(), float64(*postureColumn.field(record.VulnerabilityPosture)))
+			}
+		}
+	}
+
+	writeMetricHeader(out, "cluster_runtime_namespaces", "Number of namespaces of the cluster with runtime results.")
+	for _, record := range records {
+		if record.RuntimeNamespaces != nil {
+			writeSample(out, "cluster_runtime_namespaces", record.clusterLabels(), float64(*record.RuntimeNamespaces))
+		}
+	}
+
+	writeMetricHeader(out, "cluster_runtime_workloads", "Number of workloads of the cluster with runtime results per workload type.")
+	for _, record := range records {
+		workloadTypes := make([]string, 0, len(record.WorkloadTypes))
+		for workloadType := range record.WorkloadTypes {
+			workloadTypes = append(workloadTypes, workloadType)
+		}
+		sort.Strings(workloadTypes)
+		for _, workloadType := range workloadTypes {
+			labels := record.clusterLabels([2]string{"type", workloadType})
+			writeSample(out, "cluster_runtime_workloads", labels, float64(record.WorkloadTypes[workloadType]))
+		}
+	}
+
+	writeMetricHeader(out, "cluster_agents", "Number of agents of the cluster per status.")
+	for _, record := range records {
+		for _, status := range []struct {
+			name  string
+			count int
+		}{
+			{string(model.AgentStatusUpToDate), record.AgentsUpToDate},
+			{string(model.AgentStatusAlmostOutOfDate), record.AgentsAlmostOutOfDate},
+			{string(model.AgentStatusOutOfDate), record.AgentsOutOfDate},
+			{string(model.AgentStatusDisconnected), record.AgentsDisconnected},
+		} {
+			writeSample(out, "cluster_agents", record.clusterLabels([2]string{"status", status.name}), float64(status.count))
+		}
+	}
+
+	writeMetricHeader(out, "cluster_agent_info", "Agent status, version and version compliance of the cluster, always 1.")
+	for _, record := range records {
+		labels := record.clusterLabels([2]string{"status", record.AgentStatus}, [2]string{"version", record.AgentVersion}, [2]string{"compliance", record.VersionCompliance})
+		writeSample(out, "cluster_agent_info", labels, 1)
+	}
+
+	return out.Flush()
+}
+
+// clusterSeries is the record of a cluster with the labels identifying its series. Names are only
+// unique within a cloud account and region, so both are labels too.
+type clusterSeries struct {
+	ClusterRecord
+	labels [][2]string
+}
+
+func newClusterSeries(cluster model.ClusterWithAgentMetadata) clusterSeries {
+	record := newClusterRecord(cluster)
+	return clusterSeries{
+		ClusterRecord: record,
+		labels: [][2]string{{"cluster", record.Name}, {"provider", record.Provider}, {"account_id", cluster.AccountID},
+			{"region", cluster.Region}, {"environment", record.Environment}},
+	}
+}
+
+// clusterLabels returns a new slice with the labels of the cluster followed by extra ones.
+func (s clusterSeries) clusterLabels(extra ...[2]string) [][2]string {
+	return append(append(make([][2]string, 0, len(s.labels)+len(extra)), s.labels...), extra...)
+}
+
+func writeMetricHeader(out io.Writer, name, help string) {
+	fmt.Fprintf(out, "# HELP %s%s %s\n", metricPrefix, name, help)
+	fmt.Fprintf(out, "# TYPE %s%s gauge\n", metricPrefix, name)
+}
+
+func writeSample(out io.Writer, name string, labels [][2]string, value float64) {
+	fmt.Fprintf(out, "%s%s", metricPrefix, name)
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels))
+		for _, label := range labels {
+			pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", label[0], escapeLabelValue(label[1])))
+		}
+		fmt.Fprintf(out, "{%s}", strings.Join(pairs, ","))
+	}
+	fmt.Fprintf(out, " %s\n", strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// escapeLabelValue escapes backslashes, double quotes and line feeds as required by the exposition format.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(value)
+}
+
+func atof(value string) float64 {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return number
+}
+
+func boolToFloat(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
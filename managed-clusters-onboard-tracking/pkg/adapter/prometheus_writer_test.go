@@ -0,0 +1,36 @@
+package adapter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusSeriesUniqueForSameNamedClusters(t *testing.T) {
+	clusters := testClusters()
+	twin := clusters[0]
+	twin.AccountID = "2"
+	clusters = append(clusters, twin)
+	twin.AccountID, twin.Region = "1", "us-east-1"
+	clusters = append(clusters, twin)
+
+	var out bytes.Buffer
+	if err := (PrometheusWriter{}).Write(&out, clusters); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		series := line[:strings.LastIndex(line, " ")]
+		if seen[series] {
+			t.Errorf("duplicate series %s", series)
+		}
+		seen[series] = true
+	}
+	if want := `account_id="2",region="eu-west-1"`; !strings.Contains(out.String(), want) {
+		t.Errorf("series lack the %s labels", want)
+	}
+}
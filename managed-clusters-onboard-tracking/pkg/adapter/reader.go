@@ -0,0 +1,219 @@
+package adapter
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/classifier"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadFromFile loads clusters back from a CSV, JSON or NDJSON report, picking the format from the extension.
+func ReadFromFile(fileName string) ([]model.ClusterWithAgentMetadata, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []ClusterRecord
+	switch format := FormatFromFileName(fileName); format {
+	case FormatCSV:
+		records, err = readCSV(file)
+	case FormatJSON:
+		err = json.NewDecoder(file).Decode(&records)
+	case FormatNDJSON:
+		records, err = readNDJSON(file)
+	default:
+		return nil, fmt.Errorf("reading %s reports is not supported", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", fileName, err)
+	}
+
+	clusters := make([]model.ClusterWithAgentMetadata, 0, len(records))
+	for _, record := range records {
+		clusters = append(clusters, record.toCluster())
+	}
+	return clusters, nil
+}
+
+func readCSV(r io.Reader) ([]ClusterRecord, error) {
+	reader := csv.NewReader(r)
+	rows, err := reader.ReadAll()
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+
+	// Columns are looked up by name so reports from older versions with fewer columns can be read
+	header := rows[0]
+	var records []ClusterRecord
+	for _, row := range rows[1:] {
+		values := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(row) {
+				values[column] = row[i]
+			}
+		}
+		records = append(records, newClusterRecordFromCSV(values))
+	}
+	return records, nil
+}
+
+func readNDJSON(r io.Reader) ([]ClusterRecord, error) {
+	var records []ClusterRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var record ClusterRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+func newClusterRecordFromCSV(values map[string]string) ClusterRecord {
+	nodeCount, _ := strconv.Atoi(values["node_count"])
+	agentConnected, _ := strconv.ParseBool(values["agentConnected"])
+	runtimeEnabled, _ := strconv.ParseBool(values["runtime_enabled"])
+	mixedAgentVersions, _ := strconv.ParseBool(values["mixed_agent_versions"])
+	agentsUpToDate, _ := strconv.Atoi(values["agents_up_to_date"])
+	agentsAlmostOutOfDate, _ := strconv.Atoi(values["agents_almost_out_of_date"])
+	agentsOutOfDate, _ := strconv.Atoi(values["agents_out_of_date"])
+	agentsDisconnected, _ := strconv.Atoi(values["agents_disconnected"])
+	stale, _ := strconv.ParseBool(values["stale"])
+	agentsStale, _ := strconv.Atoi(values["agents_stale"])
+
+	record := ClusterRecord{
+		Name:                   values["name"],
+		NodeCount:              nodeCount,
+		AgentConnected:         agentConnected,
+		NodesConnected:         values["nodes_connected"],
+		AgentStatus:            values["agent_status"],
+		AgentVersion:           values["agent_version"],
+		Provider:               values["provider"],
+		Environment:            values["environment"],
+		RuntimeEnabled:         runtimeEnabled,
+		RuntimeResults:         values["runtime_results"],
+		RuntimeWorkloads:       values["runtime_workloads"],
+		RuntimeVulnerabilities: values["runtime_vulnerabilities"],
+		AgentsUpToDate:         agentsUpToDate,
+		AgentsAlmostOutOfDate:  agentsAlmostOutOfDate,
+		AgentsOutOfDate:        agentsOutOfDate,
+		AgentsDisconnected:     agentsDisconnected,
+		Stale:                  stale,
+		AgentsStale:            agentsStale,
+		AgentLastSeen:          values["agent_last_seen"],
+		SecondsSinceLastSeen:   values["seconds_since_last_seen"],
+		MixedAgentVersions:     mixedAgentVersions,
+		VersionCompliance:      values["version_compliance"],
+		Dimensions:             make(map[string]string),
+	}
+	if errors := values["errors"]; errors != "" {
+		record.Errors = strings.Split(errors, "; ")
+	}
+	if agentVersions := values["agent_versions"]; agentVersions != "" {
+		record.AgentVersions = strings.Split(agentVersions, " ")
+	}
+	if reasons := values["version_compliance_reasons"]; reasons != "" {
+		record.ComplianceReasons = strings.Split(reasons, "; ")
+	}
+
+	// Columns beyond the fixed ones are the vulnerability posture or custom dimensions
+	for column, value := range values {
+		if columnIndex(column) >= 0 {
+			continue
+		}
+		i := postureColumnIndex(column)
+		if i < 0 && !isWorkloadColumn(column) {
+			record.Dimensions[column] = value
+			continue
+		}
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		switch {
+		case i >= 0:
+			if record.VulnerabilityPosture == nil {
+				record.VulnerabilityPosture = &model.VulnerabilityPosture{}
+			}
+			*postureColumns[i].field(record.VulnerabilityPosture) = count
+		case column == runtimeNamespacesColumn:
+			record.RuntimeNamespaces = &count
+		default:
+			if record.WorkloadTypes == nil {
+				record.WorkloadTypes = make(map[string]int)
+			}
+			record.WorkloadTypes[strings.TrimPrefix(column, workloadTypeColumnPrefix)] = count
+		}
+	}
+	return record
+}
+
+// toCluster converts a record back to the model, as far as the report preserves it.
+func (r ClusterRecord) toCluster() model.ClusterWithAgentMetadata {
+	dimensions := map[string]string{classifier.EnvironmentDimension: r.Environment}
+	for dimension, value := range r.Dimensions {
+		dimensions[dimension] = value
+	}
+	// Last seen columns are empty or N/A when unknown, leaving the zero values
+	lastSeen, _ := time.Parse(time.RFC3339, r.AgentLastSeen)
+	secondsSinceLastSeen, _ := strconv.Atoi(r.SecondsSinceLastSeen)
+
+	nodesConnected := r.NodesConnected
+	if nodesConnected == "" {
+		nodesConnected = "0"
+	}
+
+	// The report only keeps the workload coverage counts, not the workloads themselves
+	var workloadSummary *model.WorkloadSummary
+	if r.RuntimeNamespaces != nil {
+		workloadSummary = &model.WorkloadSummary{Namespaces: *r.RuntimeNamespaces, Types: r.WorkloadTypes}
+	}
+
+	return model.ClusterWithAgentMetadata{
+		ClusterInfo: model.ClusterInfo{
+			Name:           r.Name,
+			NodeCount:      r.NodeCount,
+			AgentConnected: r.AgentConnected,
+			Provider:       r.Provider,
+		},
+		NodesConnected:         nodesConnected,
+		AgentStatus:            r.AgentStatus,
+		AgentVersion:           r.AgentVersion,
+		RuntimeEnabled:         r.RuntimeEnabled,
+		RuntimeResults:         r.RuntimeResults,
+		RuntimeWorkloads:       r.RuntimeWorkloads,
+		RuntimeVulnerabilities: r.RuntimeVulnerabilities,
+		VulnerabilityPosture:   r.VulnerabilityPosture,
+		WorkloadSummary:        workloadSummary,
+		AgentStatusCounts: map[string]int{
+			string(model.AgentStatusUpToDate):        r.AgentsUpToDate,
+			string(model.AgentStatusAlmostOutOfDate): r.AgentsAlmostOutOfDate,
+			string(model.AgentStatusOutOfDate):       r.AgentsOutOfDate,
+			string(model.AgentStatusDisconnected):    r.AgentsDisconnected,
+		},
+		LastSeen:                 lastSeen,
+		SinceLastSeen:            time.Duration(secondsSinceLastSeen) * time.Second,
+		StaleAgents:              r.AgentsStale,
+		Stale:                    r.Stale,
+		AgentVersions:            r.AgentVersions,
+		VersionCompliance:        r.VersionCompliance,
+		VersionComplianceReasons: r.ComplianceReasons,
+		Dimensions:               dimensions,
+		Errors:                   r.Errors,
+	}
+}
@@ -0,0 +1,404 @@
+package adapter
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/classifier"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/version"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClusterRecord is the flat representation of a cluster shared by every output format.
+// JSON field names match the CSV header so consumers can switch formats transparently.
+type ClusterRecord struct {
+	Name                   string   `json:"name"`
+	NodeCount              int      `json:"node_count"`
+	AgentConnected         bool     `json:"agentConnected"`
+	NodesConnected         string   `json:"nodes_connected"`
+	AgentStatus            string   `json:"agent_status"`
+	AgentVersion           string   `json:"agent_version"`
+	Provider               string   `json:"provider"`
+	Environment            string   `json:"environment"`
+	RuntimeEnabled         bool     `json:"runtime_enabled"`
+	RuntimeResults         string   `json:"runtime_results"`
+	RuntimeWorkloads       string   `json:"runtime_workloads"`
+	RuntimeVulnerabilities string   `json:"runtime_vulnerabilities"`
+	AgentsUpToDate         int      `json:"agents_up_to_date"`
+	AgentsAlmostOutOfDate  int      `json:"agents_almost_out_of_date"`
+	AgentsOutOfDate        int      `json:"agents_out_of_date"`
+	AgentsDisconnected     int      `json:"agents_disconnected"`
+	Stale                  bool     `json:"stale"`
+	AgentsStale            int      `json:"agents_stale"`
+	AgentLastSeen          string   `json:"agent_last_seen"`
+	SecondsSinceLastSeen   string   `json:"seconds_since_last_seen"`
+	AgentVersions          []string `json:"agent_versions"`
+	MixedAgentVersions     bool     `json:"mixed_agent_versions"`
+	VersionCompliance      string   `json:"version_compliance"`
+	ComplianceReasons      []string `json:"version_compliance_reasons"`
+	Errors                 []string `json:"errors"`
+	// VulnerabilityPosture fields and workload coverage are only present when runtime details were collected
+	*model.VulnerabilityPosture
+	RuntimeNamespaces *int `json:"runtime_namespaces,omitempty"`
+	// WorkloadTypes are written as top-level workloads_<type> fields by MarshalJSON
+	WorkloadTypes map[string]int `json:"-"`
+	// Dimensions holds custom classification dimensions other than the environment, written as
+	// top-level fields by MarshalJSON
+	Dimensions map[string]string `json:"-"`
+}
+
+// Optional workload coverage columns, workload type columns are named after the type, such as workloads_deployment
+const (
+	runtimeNamespacesColumn  = "runtime_namespaces"
+	workloadTypeColumnPrefix = "workloads_"
+)
+
+// Objects earlier JSON reports nested the custom dimensions and workload types in
+const (
+	legacyDimensionsField    = "dimensions"
+	legacyWorkloadTypesField = "workload_types"
+)
+
+var csvHeader = []string{"name", "node_count", "agentConnected", "nodes_connected", "agent_status", "agent_version", "provider", "environment", "runtime_enabled", "runtime_results", "runtime_workloads", "runtime_vulnerabilities", "agents_up_to_date", "agents_almost_out_of_date", "agents_out_of_date", "agents_disconnected", "stale", "agents_stale", "agent_last_seen", "seconds_since_last_seen", "agent_versions", "mixed_agent_versions", "version_compliance", "version_compliance_reasons", "errors"}
+
+func newClusterRecord(cluster model.ClusterWithAgentMetadata) ClusterRecord {
+	errors := emptyIfNil(cluster.Errors)
+
+	versionCompliance := cluster.VersionCompliance
+	if versionCompliance == "" {
+		versionCompliance = version.NotEvaluated
+	}
+
+	agentLastSeen, secondsSinceLastSeen := "", "N/A"
+	if !cluster.LastSeen.IsZero() {
+		agentLastSeen = cluster.LastSeen.UTC().Format(time.RFC3339)
+		secondsSinceLastSeen = strconv.Itoa(int(cluster.SinceLastSeen.Seconds()))
+	}
+
+	var runtimeNamespaces *int
+	var workloadTypes map[string]int
+	if cluster.HasWorkloadCoverage() {
+		namespaces := cluster.RuntimeNamespaceCount()
+		runtimeNamespaces = &namespaces
+		workloadTypes = make(map[string]int)
+		for workloadType, count := range cluster.WorkloadTypeCounts() {
+			workloadTypes[valueOrUnknown(workloadType)] += count
+		}
+	}
+
+	dimensions := make(map[string]string)
+	for dimension, value := range cluster.Dimensions {
+		if dimension != classifier.EnvironmentDimension {
+			dimensions[dimension] = value
+		}
+	}
+
+	return ClusterRecord{
+		Name:                   cluster.Name,
+		NodeCount:              cluster.NodeCount,
+		AgentConnected:         cluster.AgentConnected,
+		NodesConnected:         cluster.NodesConnected,
+		AgentStatus:            cluster.AgentStatus,
+		AgentVersion:           cluster.AgentVersion,
+		Provider:               cluster.Provider,
+		Environment:            environmentOf(cluster),
+		RuntimeEnabled:         cluster.RuntimeEnabled,
+		RuntimeResults:         cluster.RuntimeResults,
+		RuntimeWorkloads:       cluster.RuntimeWorkloads,
+		RuntimeVulnerabilities: cluster.RuntimeVulnerabilities,
+		AgentsUpToDate:         cluster.AgentStatusCounts[string(model.AgentStatusUpToDate)],
+		AgentsAlmostOutOfDate:  cluster.AgentStatusCounts[string(model.AgentStatusAlmostOutOfDate)],
+		AgentsOutOfDate:        cluster.AgentStatusCounts[string(model.AgentStatusOutOfDate)],
+		AgentsDisconnected:     cluster.AgentStatusCounts[string(model.AgentStatusDisconnected)],
+		Stale:                  cluster.Stale,
+		AgentsStale:            cluster.StaleAgents,
+		AgentLastSeen:          agentLastSeen,
+		SecondsSinceLastSeen:   secondsSinceLastSeen,
+		AgentVersions:          emptyIfNil(cluster.AgentVersions),
+		MixedAgentVersions:     cluster.MixedAgentVersions(),
+		VersionCompliance:      versionCompliance,
+		ComplianceReasons:      emptyIfNil(cluster.VersionComplianceReasons),
+		Errors:                 errors,
+		VulnerabilityPosture:   cluster.VulnerabilityPosture,
+		RuntimeNamespaces:      runtimeNamespaces,
+		WorkloadTypes:          workloadTypes,
+		Dimensions:             dimensions,
+	}
+}
+
+// csvRow returns the record values in csvHeader order.
+func (r ClusterRecord) csvRow() []string {
+	return []string{
+		r.Name,
+		fmt.Sprintf("%d", r.NodeCount),
+		fmt.Sprintf("%v", r.AgentConnected),
+		r.NodesConnected,
+		r.AgentStatus,
+		r.AgentVersion,
+		r.Provider,
+		r.Environment,
+		strconv.FormatBool(r.RuntimeEnabled),
+		r.RuntimeResults,
+		r.RuntimeWorkloads,
+		r.RuntimeVulnerabilities,
+		strconv.Itoa(r.AgentsUpToDate),
+		strconv.Itoa(r.AgentsAlmostOutOfDate),
+		strconv.Itoa(r.AgentsOutOfDate),
+		strconv.Itoa(r.AgentsDisconnected),
+		strconv.FormatBool(r.Stale),
+		strconv.Itoa(r.AgentsStale),
+		r.AgentLastSeen,
+		r.SecondsSinceLastSeen,
+		strings.Join(r.AgentVersions, " "),
+		strconv.FormatBool(r.MixedAgentVersions),
+		r.VersionCompliance,
+		strings.Join(r.ComplianceReasons, "; "),
+		strings.Join(r.Errors, "; "),
+	}
+}
+
+// clusterRecordFields has the fields of ClusterRecord without its JSON methods.
+type clusterRecordFields ClusterRecord
+
+// MarshalJSON writes the custom dimensions and workload types as top-level fields named
+// after their CSV columns, next to the fixed ones.
+func (r ClusterRecord) MarshalJSON() ([]byte, error) {
+	fields, err := json.Marshal(clusterRecordFields(r))
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]interface{}, len(r.Dimensions)+len(r.WorkloadTypes))
+	for dimension, value := range r.Dimensions {
+		columns[dimension] = value
+	}
+	for workloadType, count := range r.WorkloadTypes {
+		columns[workloadTypeColumnPrefix+workloadType] = count
+	}
+	if len(columns) == 0 {
+		return fields, nil
+	}
+	extra, err := json.Marshal(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both are non-empty JSON objects, their members are joined into one object
+	return append(append(fields[:len(fields)-1], ','), extra[1:]...), nil
+}
+
+// UnmarshalJSON reads the fields written by MarshalJSON, also accepting the nested
+// dimensions and workload_types objects of earlier reports.
+func (r *ClusterRecord) UnmarshalJSON(data []byte) error {
+	var fields clusterRecordFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	*r = ClusterRecord(fields)
+	for key, value := range members {
+		switch {
+		case columnIndex(key) >= 0 || postureColumnIndex(key) >= 0 || key == runtimeNamespacesColumn:
+			continue
+		case key == legacyDimensionsField:
+			if err := json.Unmarshal(value, &r.Dimensions); err != nil {
+				return err
+			}
+		case key == legacyWorkloadTypesField:
+			if err := json.Unmarshal(value, &r.WorkloadTypes); err != nil {
+				return err
+			}
+		case strings.HasPrefix(key, workloadTypeColumnPrefix):
+			var count int
+			if err := json.Unmarshal(value, &count); err != nil {
+				return fmt.Errorf("field %s: %v", key, err)
+			}
+			if r.WorkloadTypes == nil {
+				r.WorkloadTypes = make(map[string]int)
+			}
+			r.WorkloadTypes[strings.TrimPrefix(key, workloadTypeColumnPrefix)] = count
+		default:
+			var dimension string
+			if err := json.Unmarshal(value, &dimension); err != nil {
+				return fmt.Errorf("field %s: %v", key, err)
+			}
+			if r.Dimensions == nil {
+				r.Dimensions = make(map[string]string)
+			}
+			r.Dimensions[key] = dimension
+		}
+	}
+	return nil
+}
+
+// postureColumns are the optional vulnerability posture columns, written after the fixed ones
+// when at least one cluster has runtime details.
+var postureColumns = []struct {
+	name  string
+	help  string
+	field func(*model.VulnerabilityPosture) *int
+}{
+	{"vulns_critical", "Number of critical vulnerabilities across the runtime results of the cluster.", func(p *model.VulnerabilityPosture) *int { return &p.Critical }},
+	{"vulns_high", "Number of high vulnerabilities across the runtime results of the cluster.", func(p *model.VulnerabilityPosture) *int { return &p.High }},
+	{"vulns_medium", "Number of medium vulnerabilities across the runtime results of the cluster.", func(p *model.VulnerabilityPosture) *int { return &p.Medium }},
+	{"vulns_low", "Number of low vulnerabilities across the runtime results of the cluster.", func(p *model.VulnerabilityPosture) *int { return &p.Low }},
+	{"running_vulns", "Number of vulnerabilities in packages in use across the runtime results of the cluster.", func(p *model.VulnerabilityPosture) *int { return &p.Running }},
+	{"exploitable_vulns", "Number of vulnerabilities with a known exploit across the runtime results of the cluster.", func(p *model.VulnerabilityPosture) *int { return &p.Exploitable }},
+	{"policy_passed", "Number of runtime results of the cluster passing their policies.", func(p *model.VulnerabilityPosture) *int { return &p.PolicyPassed }},
+	{"policy_failed", "Number of runtime results of the cluster failing their policies.", func(p *model.VulnerabilityPosture) *int { return &p.PolicyFailed }},
+	{"accepted_risk", "Number of runtime results of the cluster with an accepted risk.", func(p *model.VulnerabilityPosture) *int { return &p.AcceptedRisk }},
+}
+
+// postureColumnIndex returns the position of a vulnerability posture column, or -1 when missing.
+func postureColumnIndex(column string) int {
+	for i, postureColumn := range postureColumns {
+		if postureColumn.name == column {
+			return i
+		}
+	}
+	return -1
+}
+
+// optionalColumns returns the columns written after csvHeader: the vulnerability posture and workload
+// coverage when any cluster has them, then the custom dimensions.
+func optionalColumns(clusterWithAgentMetadata []model.ClusterWithAgentMetadata) []string {
+	var columns []string
+	for _, cluster := range clusterWithAgentMetadata {
+		if cluster.VulnerabilityPosture != nil {
+			for _, postureColumn := range postureColumns {
+				columns = append(columns, postureColumn.name)
+			}
+			break
+		}
+	}
+
+	seen := make(map[string]bool)
+	var workloadTypeColumns []string
+	for _, cluster := range clusterWithAgentMetadata {
+		if !cluster.HasWorkloadCoverage() {
+			continue
+		}
+		seen[runtimeNamespacesColumn] = true
+		for workloadType := range cluster.WorkloadTypeCounts() {
+			column := workloadTypeColumnPrefix + valueOrUnknown(workloadType)
+			if !seen[column] {
+				seen[column] = true
+				workloadTypeColumns = append(workloadTypeColumns, column)
+			}
+		}
+	}
+	if seen[runtimeNamespacesColumn] {
+		sort.Strings(workloadTypeColumns)
+		columns = append(append(columns, runtimeNamespacesColumn), workloadTypeColumns...)
+	}
+
+	return append(columns, customDimensionNames(clusterWithAgentMetadata)...)
+}
+
+// optionalValues returns the record values of the optional columns, N/A for a missing posture.
+func (r ClusterRecord) optionalValues(columns []string) []string {
+	values := make([]string, 0, len(columns))
+	for _, column := range columns {
+		i := postureColumnIndex(column)
+		switch {
+		case i >= 0 && r.VulnerabilityPosture == nil:
+			values = append(values, "N/A")
+		case i >= 0:
+			values = append(values, strconv.Itoa(*postureColumns[i].field(r.VulnerabilityPosture)))
+		case isWorkloadColumn(column) && r.RuntimeNamespaces == nil:
+			values = append(values, "N/A")
+		case column == runtimeNamespacesColumn:
+			values = append(values, strconv.Itoa(*r.RuntimeNamespaces))
+		case isWorkloadColumn(column):
+			values = append(values, strconv.Itoa(r.WorkloadTypes[strings.TrimPrefix(column, workloadTypeColumnPrefix)]))
+		default:
+			values = append(values, r.Dimensions[column])
+		}
+	}
+	return values
+}
+
+// isWorkloadColumn reports whether column is one of the optional workload coverage columns.
+func isWorkloadColumn(column string) bool {
+	return column == runtimeNamespacesColumn || strings.HasPrefix(column, workloadTypeColumnPrefix)
+}
+
+// IsReportColumn reports whether name, in any case, is a column of the cluster report or a field of its
+// JSON records. Custom dimensions are written next to them, so they cannot use these names.
+func IsReportColumn(name string) bool {
+	if strings.HasPrefix(strings.ToLower(name), workloadTypeColumnPrefix) {
+		return true
+	}
+	columns := append([]string{runtimeNamespacesColumn, legacyDimensionsField, legacyWorkloadTypesField}, csvHeader...)
+	for _, postureColumn := range postureColumns {
+		columns = append(columns, postureColumn.name)
+	}
+	for _, column := range columns {
+		if strings.EqualFold(name, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// customDimensionNames returns the sorted custom dimensions found across all clusters.
+func customDimensionNames(clusterWithAgentMetadata []model.ClusterWithAgentMetadata) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, cluster := range clusterWithAgentMetadata {
+		for dimension := range cluster.Dimensions {
+			if dimension != classifier.EnvironmentDimension && !seen[dimension] {
+				seen[dimension] = true
+				names = append(names, dimension)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func emptyIfNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// columnIndex returns the position of a fixed column in csvHeader, or -1 when missing.
+func columnIndex(column string) int {
+	for i, name := range csvHeader {
+		if name == column {
+			return i
+		}
+	}
+	return -1
+}
+
+// environmentOf returns the environment dimension of the cluster.
+func environmentOf(cluster model.ClusterWithAgentMetadata) string {
+	if environment, ok := cluster.Dimensions[classifier.EnvironmentDimension]; ok {
+		return environment
+	}
+	return classifier.Unknown
+}
+
+// breakdown describes a dimension reports are grouped by.
+type breakdown struct {
+	title  string
+	column string
+	key    func(model.ClusterWithAgentMetadata) string
+}
+
+var breakdowns = []breakdown{
+	{title: "Provider", column: "provider", key: func(c model.ClusterWithAgentMetadata) string { return c.Provider }},
+	{title: "Region", column: "region", key: func(c model.ClusterWithAgentMetadata) string { return c.Region }},
+	{title: "Environment", column: "environment", key: environmentOf},
+	{title: "Agent Status", column: "agent_status", key: func(c model.ClusterWithAgentMetadata) string { return c.AgentStatus }},
+}
@@ -0,0 +1,129 @@
+package adapter
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/classifier"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/logging"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logging.Log = logrus.NewEntry(logger)
+	os.Exit(m.Run())
+}
+
+// testClusters returns clusters filling every column a report can hold.
+func testClusters() []model.ClusterWithAgentMetadata {
+	lastSeen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return []model.ClusterWithAgentMetadata{
+		{
+			ClusterInfo:    model.ClusterInfo{Name: "prod-eu", Provider: "aws", AccountID: "1", Region: "eu-west-1", NodeCount: 3, AgentConnected: true},
+			NodesConnected: "3",
+			AgentStatus:    string(model.AgentStatusUpToDate),
+			AgentVersion:   "13.0.0",
+			RuntimeEnabled: true,
+			AgentStatusCounts: map[string]int{
+				string(model.AgentStatusUpToDate):  2,
+				string(model.AgentStatusOutOfDate): 1,
+			},
+			LastSeen:                 lastSeen,
+			SinceLastSeen:            90 * time.Second,
+			StaleAgents:              1,
+			AgentVersions:            []string{"12.16.1", "13.0.0"},
+			VersionCompliance:        "non-compliant",
+			VersionComplianceReasons: []string{"12.16.1 is below the minimum version"},
+			RuntimeResults:           "4",
+			RuntimeWorkloads:         "2",
+			RuntimeVulnerabilities:   "40",
+			VulnerabilityPosture:     &model.VulnerabilityPosture{Critical: 1, High: 2, Medium: 3, Low: 4, Running: 5, Exploitable: 6, PolicyPassed: 7, PolicyFailed: 8, AcceptedRisk: 9},
+			Workloads: []model.RuntimeWorkload{
+				{Namespace: "payments", Name: "api", Type: "deployment"},
+				{Namespace: "payments", Name: "db", Type: "statefulset"},
+			},
+			Dimensions: map[string]string{classifier.EnvironmentDimension: "production", "business_unit": "emea"},
+			Errors:     []string{"runtime lookup interrupted"},
+		},
+		{
+			ClusterInfo:            model.ClusterInfo{Name: "dev-us", Provider: "gcp", NodeCount: 1},
+			NodesConnected:         "0",
+			AgentStatus:            "N/A",
+			AgentVersion:           "N/A",
+			Stale:                  true,
+			RuntimeResults:         "N/A",
+			RuntimeWorkloads:       "N/A",
+			RuntimeVulnerabilities: "N/A",
+			Dimensions:             map[string]string{classifier.EnvironmentDimension: "development", "business_unit": "core"},
+		},
+	}
+}
+
+func TestReportRoundTrip(t *testing.T) {
+	clusters := testClusters()
+	for _, fileName := range []string{"clusters.csv", "clusters.json", "clusters.ndjson"} {
+		t.Run(fileName, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), fileName)
+			writer, err := NewWriter(FormatFromFileName(path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteToFile(path, writer, clusters); err != nil {
+				t.Fatalf("WriteToFile returned an error: %v", err)
+			}
+
+			read, err := ReadFromFile(path)
+			if err != nil {
+				t.Fatalf("ReadFromFile returned an error: %v", err)
+			}
+			if len(read) != len(clusters) {
+				t.Fatalf("read %d clusters, want %d", len(read), len(clusters))
+			}
+			for i := range clusters {
+				want, got := newClusterRecord(clusters[i]), newClusterRecord(read[i])
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("cluster %s read back as\n%+v\nwant\n%+v", clusters[i].Name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReportColumnsRejectedAsDimensions(t *testing.T) {
+	tests := []struct {
+		dimension string
+		rejected  bool
+	}{
+		{dimension: "business_unit"},
+		{dimension: classifier.EnvironmentDimension},
+		{dimension: "provider", rejected: true},
+		{dimension: "Name", rejected: true},
+		{dimension: "stale", rejected: true},
+		{dimension: "errors", rejected: true},
+		{dimension: "vulns_critical", rejected: true},
+		{dimension: "runtime_namespaces", rejected: true},
+		{dimension: "workloads_deployment", rejected: true},
+		{dimension: "dimensions", rejected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.dimension, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.json")
+			rules := fmt.Sprintf(`{"dimensions": {%q: [{"field": "region", "pattern": ".", "value": "x"}]}}`, test.dimension)
+			if err := os.WriteFile(path, []byte(rules), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := classifier.LoadRules(path, IsReportColumn)
+			if rejected := err != nil; rejected != test.rejected {
+				t.Errorf("rules with dimension %s rejected: %v, want %v (error %v)", test.dimension, rejected, test.rejected, err)
+			}
+		})
+	}
+}
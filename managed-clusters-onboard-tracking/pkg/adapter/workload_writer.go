@@ -0,0 +1,114 @@
+package adapter
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WorkloadRecord is the flat representation of a workload with runtime results.
+type WorkloadRecord struct {
+	Cluster      string   `json:"cluster"`
+	Provider     string   `json:"provider"`
+	Environment  string   `json:"environment"`
+	Namespace    string   `json:"namespace"`
+	WorkloadType string   `json:"workload_type"`
+	Workload     string   `json:"workload"`
+	Containers   []string `json:"containers"`
+	Results      int      `json:"results"`
+}
+
+var workloadCSVHeader = []string{"cluster", "provider", "environment", "namespace", "workload_type", "workload", "containers", "results"}
+
+// newWorkloadRecords returns one record per workload with runtime results, in cluster order.
+func newWorkloadRecords(clusterWithAgentMetadata []model.ClusterWithAgentMetadata) []WorkloadRecord {
+	var records []WorkloadRecord
+	for _, cluster := range clusterWithAgentMetadata {
+		environment := environmentOf(cluster)
+		for _, workload := range cluster.Workloads {
+			records = append(records, WorkloadRecord{
+				Cluster:      cluster.Name,
+				Provider:     cluster.Provider,
+				Environment:  environment,
+				Namespace:    workload.Namespace,
+				WorkloadType: valueOrUnknown(workload.Type),
+				Workload:     workload.Name,
+				Containers:   emptyIfNil(workload.Containers),
+				Results:      workload.Results,
+			})
+		}
+	}
+	return records
+}
+
+// csvRow returns the record values in workloadCSVHeader order.
+func (r WorkloadRecord) csvRow() []string {
+	return []string{
+		r.Cluster,
+		r.Provider,
+		r.Environment,
+		r.Namespace,
+		r.WorkloadType,
+		r.Workload,
+		strings.Join(r.Containers, " "),
+		strconv.Itoa(r.Results),
+	}
+}
+
+// NewWorkloadWriter returns the Writer of the workload-level runtime coverage report for the given format.
+func NewWorkloadWriter(format string) (Writer, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return WorkloadCSVWriter{}, nil
+	case FormatJSON:
+		return WorkloadJSONWriter{}, nil
+	case FormatNDJSON:
+		return WorkloadNDJSONWriter{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported workload report format %q", format)
+	}
+}
+
+// WorkloadCSVWriter writes one row per workload preceded by a header.
+type WorkloadCSVWriter struct{}
+
+func (WorkloadCSVWriter) Write(w io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	writer := csv.NewWriter(w)
+	writer.Write(workloadCSVHeader)
+	for _, record := range newWorkloadRecords(clusterWithAgentMetadata) {
+		writer.Write(record.csvRow())
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WorkloadJSONWriter writes the workloads as a single indented JSON array.
+type WorkloadJSONWriter struct{}
+
+func (WorkloadJSONWriter) Write(w io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	records := newWorkloadRecords(clusterWithAgentMetadata)
+	if records == nil {
+		records = []WorkloadRecord{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// WorkloadNDJSONWriter writes one JSON object per line, one line per workload.
+type WorkloadNDJSONWriter struct{}
+
+func (WorkloadNDJSONWriter) Write(w io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	encoder := json.NewEncoder(w)
+	for _, record := range newWorkloadRecords(clusterWithAgentMetadata) {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
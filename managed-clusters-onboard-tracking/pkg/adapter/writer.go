@@ -0,0 +1,103 @@
+package adapter
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/logging"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Supported output formats
+const (
+	FormatCSV        = "csv"
+	FormatJSON       = "json"
+	FormatNDJSON     = "ndjson"
+	FormatXLSX       = "xlsx"
+	FormatHTML       = "html"
+	FormatMarkdown   = "markdown"
+	FormatPrometheus = "prometheus"
+)
+
+// Writer renders the cluster report in a specific format.
+type Writer interface {
+	Write(w io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error
+}
+
+// NewWriter returns the Writer for the given format.
+func NewWriter(format string) (Writer, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return CSVWriter{}, nil
+	case FormatJSON:
+		return JSONWriter{}, nil
+	case FormatNDJSON:
+		return NDJSONWriter{}, nil
+	case FormatXLSX:
+		return XLSXWriter{}, nil
+	case FormatHTML:
+		return HTMLWriter{}, nil
+	case FormatMarkdown, "md":
+		return MarkdownWriter{}, nil
+	case FormatPrometheus, "prom":
+		return PrometheusWriter{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// FormatFromFileName infers the output format from the file extension, defaulting to CSV.
+func FormatFromFileName(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		return FormatJSON
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	case ".xlsx":
+		return FormatXLSX
+	case ".html", ".htm":
+		return FormatHTML
+	case ".md", ".markdown":
+		return FormatMarkdown
+	case ".prom":
+		return FormatPrometheus
+	default:
+		return FormatCSV
+	}
+}
+
+// WriteToFile writes the report into fileName with the given Writer. The report is written to a
+// temporary file in the same directory and renamed over fileName, so readers such as the
+// node_exporter textfile collector never see a partially written file.
+func WriteToFile(fileName string, writer Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	file, err := os.CreateTemp(filepath.Dir(fileName), "."+filepath.Base(fileName)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := writer.Write(file, clusterWithAgentMetadata); err != nil {
+		return err
+	}
+	// CreateTemp only grants access to the owner, reports are readable like files created by os.Create
+	if err := file.Chmod(0644); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), fileName); err != nil {
+		return err
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		logging.Log.Errorf("Failed to obtain current dir. Error %s", err)
+	}
+	logging.Log.Debugf("Created report file successfully. File name: %s, file path: %s", fileName, dir)
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package adapter
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"strconv"
+)
+
+const (
+	clustersSheet = "Clusters"
+	summarySheet  = "Summary"
+)
+
+// Fill colors highlighting rows that need attention
+const (
+	disconnectedFillColor = "#FFC7CE"
+	outOfDateFillColor    = "#FFEB9C"
+	staleFillColor        = "#D9D9D9"
+)
+
+var breakdownHeader = []interface{}{"clusters", "agent_connected_clusters", "runtime_enabled_clusters", "total_nodes", "nodes_connected", "node_coverage_percentage", "runtime_coverage_percentage"}
+
+// XLSXWriter writes an Excel workbook with the cluster list, a summary and one breakdown sheet per dimension.
+type XLSXWriter struct{}
+
+func (XLSXWriter) Write(w io.Writer, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	if err := file.SetSheetName("Sheet1", clustersSheet); err != nil {
+		return err
+	}
+	if err := writeClustersSheet(file, clusterWithAgentMetadata); err != nil {
+		return err
+	}
+
+	metrics, err := model.ComputeMetrics(clusterWithAgentMetadata)
+	if err != nil {
+		return err
+	}
+	if err := writeSummarySheet(file, metrics); err != nil {
+		return err
+	}
+
+	for _, breakdown := range breakdowns {
+		groups, err := model.ComputeGroupMetrics(clusterWithAgentMetadata, breakdown.key)
+		if err != nil {
+			return err
+		}
+		if err := writeBreakdownSheet(file, "By "+breakdown.title, breakdown.column, groups); err != nil {
+			return err
+		}
+	}
+
+	return file.Write(w)
+}
+
+func writeClustersSheet(file *excelize.File, clusterWithAgentMetadata []model.ClusterWithAgentMetadata) error {
+	columns := optionalColumns(clusterWithAgentMetadata)
+	header := append(append([]string{}, csvHeader...), columns...)
+
+	if err := file.SetSheetRow(clustersSheet, "A1", &header); err != nil {
+		return err
+	}
+	for i, cluster := range clusterWithAgentMetadata {
+		record := newClusterRecord(cluster)
+		values := append(record.csvRow(), record.optionalValues(columns)...)
+		row := make([]interface{}, len(values))
+		for j, value := range values {
+			// Numbers are stored as such so they can be summed and pivoted in Excel
+			if number, err := strconv.Atoi(value); err == nil {
+				row[j] = number
+			} else {
+				row[j] = value
+			}
+		}
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		if err := file.SetSheetRow(clustersSheet, cell, &row); err != nil {
+			return err
+		}
+	}
+
+	if err := file.SetPanes(clustersSheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return err
+	}
+	if len(clusterWithAgentMetadata) == 0 {
+		return nil
+	}
+
+	lastCell, err := excelize.CoordinatesToCellName(len(header), len(clusterWithAgentMetadata)+1)
+	if err != nil {
+		return err
+	}
+	rangeRef := "A1:" + lastCell
+	if err := file.AutoFilter(clustersSheet, rangeRef, nil); err != nil {
+		return err
+	}
+	return highlightAgentStatusRows(file, "A2:"+lastCell)
+}
+
+// highlightAgentStatusRows colors rows of disconnected, out of date and stale agents.
+func highlightAgentStatusRows(file *excelize.File, rangeRef string) error {
+	rules := []struct {
+		column string
+		value  string
+		color  string
+	}{
+		{"agent_status", string(model.AgentStatusDisconnected), disconnectedFillColor},
+		{"agent_status", string(model.AgentStatusOutOfDate), outOfDateFillColor},
+		{"stale", "true", staleFillColor},
+	}
+
+	var options []excelize.ConditionalFormatOptions
+	for _, rule := range rules {
+		column, err := excelize.ColumnNumberToName(columnIndex(rule.column) + 1)
+		if err != nil {
+			return err
+		}
+		style, err := file.NewConditionalStyle(&excelize.Style{
+			Fill: excelize.Fill{Type: "pattern", Color: []string{rule.color}, Pattern: 1},
+		})
+		if err != nil {
+			return err
+		}
+		options = append(options, excelize.ConditionalFormatOptions{
+			Type:     "formula",
+			Criteria: fmt.Sprintf("$%s2=\"%s\"", column, rule.value),
+			Format:   style,
+		})
+	}
+	return file.SetConditionalFormat(clustersSheet, rangeRef, options)
+}
+
+func writeSummarySheet(file *excelize.File, metrics model.Metrics) error {
+	if _, err := file.NewSheet(summarySheet); err != nil {
+		return err
+	}
+
+	rows := [][]interface{}{
+		{"metric", "value"},
+		{"Total Clusters", metrics.Clusters},
+		{"Clusters With Agent Connected", metrics.ClustersAgentConnected},
+		{"Clusters With Runtime Enabled", metrics.ClustersRuntimeEnabled},
+		{"Clusters With Incomplete Data", metrics.ClustersIncomplete},
+		{"Total Nodes", metrics.TotalNodes},
+		{"Total Nodes Connected", metrics.TotalNodesConnected},
+		{"Percentage of Nodes Connected", metrics.NodeCoverage()},
+		{"Percentage of Clusters With Runtime Enabled", metrics.RuntimeCoverage()},
+	}
+	for i, row := range rows {
+		row := row
+		if err := file.SetSheetRow(summarySheet, fmt.Sprintf("A%d", i+1), &row); err != nil {
+			return err
+		}
+	}
+	return file.SetColWidth(summarySheet, "A", "A", 45)
+}
+
+func writeBreakdownSheet(file *excelize.File, sheet, column string, groups []model.GroupMetrics) error {
+	if _, err := file.NewSheet(sheet); err != nil {
+		return err
+	}
+
+	header := append([]interface{}{column}, breakdownHeader...)
+	if err := file.SetSheetRow(sheet, "A1", &header); err != nil {
+		return err
+	}
+	for i, group := range groups {
+		row := []interface{}{
+			group.Key,
+			group.Clusters,
+			group.ClustersAgentConnected,
+			group.ClustersRuntimeEnabled,
+			group.TotalNodes,
+			group.TotalNodesConnected,
+			group.NodeCoverage(),
+			group.RuntimeCoverage(),
+		}
+		if err := file.SetSheetRow(sheet, fmt.Sprintf("A%d", i+2), &row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
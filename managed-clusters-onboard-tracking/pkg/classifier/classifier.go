@@ -0,0 +1,169 @@
+package classifier
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+const (
+	// EnvironmentDimension is the dimension reported in the environment column
+	EnvironmentDimension = "environment"
+	// Unknown is assigned to a dimension when no rule matches
+	Unknown = "unknown"
+)
+
+// Fields of model.ClusterInfo a rule can match against
+const (
+	FieldName          = "name"
+	FieldAccountID     = "account_id"
+	FieldRegion        = "region"
+	FieldResourceGroup = "resource_group"
+	FieldProvider      = "provider"
+)
+
+// Rule assigns Value to a dimension when Pattern matches the given cluster Field.
+type Rule struct {
+	Field   string `json:"field"`
+	Pattern string `json:"pattern"`
+	Value   string `json:"value"`
+
+	regex *regexp.Regexp
+}
+
+// Rules holds the classification rules per dimension, evaluated in order with the first match winning.
+// Mapping assigns dimension values to clusters by exact name and takes precedence over the rules.
+type Rules struct {
+	Dimensions map[string][]Rule            `json:"dimensions"`
+	Mapping    map[string]map[string]string `json:"mapping"`
+
+	// mappedDimensions lists dimensions only defined through Mapping
+	mappedDimensions []string
+}
+
+// DefaultRules reproduces the historical naming convention where the fourth character
+// of the cluster name identifies the environment.
+func DefaultRules() *Rules {
+	rules := &Rules{
+		Dimensions: map[string][]Rule{
+			EnvironmentDimension: {
+				{Field: FieldName, Pattern: "^...d", Value: "development"},
+				{Field: FieldName, Pattern: "^...p", Value: "production"},
+				{Field: FieldName, Pattern: "^...i", Value: "pre-production"},
+			},
+		},
+	}
+	// Default patterns are constants known to compile
+	_ = rules.compile()
+	return rules
+}
+
+// LoadRules reads classification rules from a JSON file. When the file does not define
+// an environment dimension, the default environment rules are kept. Custom dimensions are
+// written as report columns, so names for which isReserved returns true are rejected.
+func LoadRules(path string, isReserved func(name string) bool) (*Rules, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &Rules{}
+	if err := json.Unmarshal(content, rules); err != nil {
+		return nil, fmt.Errorf("error parsing classification rules %s: %v", path, err)
+	}
+	if rules.Dimensions == nil {
+		rules.Dimensions = make(map[string][]Rule)
+	}
+	if _, ok := rules.Dimensions[EnvironmentDimension]; !ok {
+		rules.Dimensions[EnvironmentDimension] = DefaultRules().Dimensions[EnvironmentDimension]
+	}
+
+	if err := rules.compile(); err != nil {
+		return nil, fmt.Errorf("error compiling classification rules %s: %v", path, err)
+	}
+	for _, dimension := range rules.dimensionNames() {
+		if dimension != EnvironmentDimension && isReserved(dimension) {
+			return nil, fmt.Errorf("error in classification rules %s: dimension %s clashes with a report column", path, dimension)
+		}
+	}
+	return rules, nil
+}
+
+// dimensionNames returns every dimension defined by the rules or the mapping.
+func (r *Rules) dimensionNames() []string {
+	names := make([]string, 0, len(r.Dimensions)+len(r.mappedDimensions))
+	for dimension := range r.Dimensions {
+		names = append(names, dimension)
+	}
+	return append(names, r.mappedDimensions...)
+}
+
+func (r *Rules) compile() error {
+	for dimension, rules := range r.Dimensions {
+		for i := range rules {
+			rule := &rules[i]
+			if _, err := clusterField(model.ClusterInfo{}, rule.Field); err != nil {
+				return fmt.Errorf("dimension %s: %v", dimension, err)
+			}
+			regex, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return fmt.Errorf("dimension %s: %v", dimension, err)
+			}
+			rule.regex = regex
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, values := range r.Mapping {
+		for dimension := range values {
+			if _, ok := r.Dimensions[dimension]; !ok && !seen[dimension] {
+				seen[dimension] = true
+				r.mappedDimensions = append(r.mappedDimensions, dimension)
+			}
+		}
+	}
+	return nil
+}
+
+// Classify returns the value of every dimension for the cluster, falling back to Unknown.
+func (r *Rules) Classify(cluster model.ClusterInfo) map[string]string {
+	dimensions := make(map[string]string, len(r.Dimensions))
+
+	for dimension, rules := range r.Dimensions {
+		dimensions[dimension] = Unknown
+		for _, rule := range rules {
+			value, _ := clusterField(cluster, rule.Field)
+			if rule.regex != nil && rule.regex.MatchString(value) {
+				dimensions[dimension] = rule.Value
+				break
+			}
+		}
+	}
+
+	for _, dimension := range r.mappedDimensions {
+		dimensions[dimension] = Unknown
+	}
+	for dimension, value := range r.Mapping[cluster.Name] {
+		dimensions[dimension] = value
+	}
+	return dimensions
+}
+
+func clusterField(cluster model.ClusterInfo, field string) (string, error) {
+	switch field {
+	case FieldName:
+		return cluster.Name, nil
+	case FieldAccountID:
+		return cluster.AccountID, nil
+	case FieldRegion:
+		return cluster.Region, nil
+	case FieldResourceGroup:
+		return cluster.ClusterResourceGroup, nil
+	case FieldProvider:
+		return cluster.Provider, nil
+	default:
+		return "", fmt.Errorf("unsupported rule field %q", field)
+	}
+}
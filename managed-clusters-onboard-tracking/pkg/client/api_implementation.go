@@ -3,8 +3,10 @@ package client
 import (
 	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/logging"
 	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"context"
 	"fmt"
 	"net/http"
+	"reflect"
 	"strconv"
 )
 
@@ -12,110 +14,223 @@ const (
 	runtimeInformationPath = "%s/api/scanning/runtime/v2/workflows/results"
 	clusterInformationPath = "%s/api/cloud/v2/dataSources/clusters"
 	agentInformationPath   = "%s/api/cloud/v2/dataSources/agents"
-)
 
-func (c *Client) GetRuntimeData(clusterName string) (model.RuntimeCluster, error) {
+	// Number of clusters requested per page from the clusters datasource
+	clusterPageSize = 100
 
-	c.ServiceName = "RUNTIME_DATA"
+	// Number of agent details requested per page from the agents datasource
+	agentPageSize = 500
 
-	filter := fmt.Sprintf("kubernetes.cluster.name = \"%s\"", clusterName)
-	pathParams := map[string]string{"filter": filter}
-	urlFormat, err := c.CreateUrl(runtimeInformationPath, pathParams)
+	// Number of runtime workflow results requested per page
+	runtimePageSize = 100
 
-	if err != nil {
-		logging.Log.Errorf("Error creating URL for service %s. Error: %v", c.ServiceName, err)
-		return model.RuntimeCluster{}, err
-	}
+	// Default upper bound of pages requested by a single call, guarding against endpoints ignoring the pagination parameters
+	defaultMaxPages = 1000
+)
 
-	req, err := c.NewRequest(http.MethodGet, urlFormat, nil)
-	if err != nil {
-		logging.Log.Errorf("Error creating request for service %s. Error: %v", c.ServiceName, err)
-		return model.RuntimeCluster{}, err
-	}
+func (c *Client) GetRuntimeData(ctx context.Context, clusterName string, allPages bool) (model.RuntimeCluster, error) {
 
-	var runtimeData model.RuntimeData
+	serviceName := "RUNTIME_DATA"
 
-	err = c.Do(req, &runtimeData)
-	if err != nil {
-		return model.RuntimeCluster{}, err
+	filter := fmt.Sprintf("kubernetes.cluster.name = \"%s\"", clusterName)
+
+	var runtimeData model.RuntimeData
+	cursor := ""
+	seenCursors := make(map[string]bool)
+
+	// Follow the next cursor until exhausted when all pages are requested, otherwise the first page is enough
+	for pages := 0; ; pages++ {
+		if pages == c.maxPages() {
+			return model.RuntimeCluster{}, fmt.Errorf("stopped fetching runtime results of cluster %s after %d pages for service %s", clusterName, pages, serviceName)
+		}
+		pathParams := map[string]string{"filter": filter, "limit": strconv.Itoa(runtimePageSize)}
+		if cursor != "" {
+			pathParams["cursor"] = cursor
+		}
+		urlFormat, err := c.CreateUrl(runtimeInformationPath, pathParams)
+
+		if err != nil {
+			logging.Log.Errorf("Error creating URL for service %s. Error: %v", serviceName, err)
+			return model.RuntimeCluster{}, err
+		}
+
+		req, err := c.NewRequest(ctx, serviceName, http.MethodGet, urlFormat, nil)
+		if err != nil {
+			logging.Log.Errorf("Error creating request for service %s. Error: %v", serviceName, err)
+			return model.RuntimeCluster{}, err
+		}
+
+		var page model.RuntimeData
+		err = c.Do(req, &page)
+		if err != nil {
+			return model.RuntimeCluster{}, err
+		}
+
+		if cursor == "" {
+			runtimeData.Page = page.Page
+		}
+		runtimeData.Data = append(runtimeData.Data, page.Data...)
+
+		cursor = page.Page.Next
+		if !allPages || cursor == "" {
+			break
+		}
+		// A cursor returned twice would walk the same pages forever
+		if seenCursors[cursor] {
+			return model.RuntimeCluster{}, fmt.Errorf("runtime results of cluster %s returned the cursor %q twice for service %s", clusterName, cursor, serviceName)
+		}
+		seenCursors[cursor] = true
 	}
+
 	runtimeCluster := model.RuntimeCluster{
 		ClusterName: clusterName,
 		IsEnabled:   isRuntimeEnabled(runtimeData),
+		ResultCount: runtimeData.Page.Matched,
+	}
+	if allPages {
+		runtimeCluster.Results = runtimeData.Data
+		runtimeCluster.Complete = true
 	}
 	return runtimeCluster, nil
 }
 
-func (c *Client) GetClusterData(limit int, filter, connected string) ([]model.ClusterInfo, error) {
-
-	c.ServiceName = "CLUSTER_DATA" // Set the service name for logging
+func (c *Client) GetClusterData(ctx context.Context, limit int, filter, connected string) ([]model.ClusterInfo, error) {
 
-	// Construct path parameters
-	pathParams := map[string]string{
-		"limit":     strconv.Itoa(limit),
-		"filter":    filter,
-		"connected": connected,
-	}
-
-	// Create URL using the client's method
-	urlFormat, err := c.CreateUrl(clusterInformationPath, pathParams)
-	if err != nil {
-		logging.Log.Errorf("Error creating URL for service %s. Error: %v", c.ServiceName, err)
-		return nil, err
-	}
-
-	// Create a new request
-	req, err := c.NewRequest(http.MethodGet, urlFormat, nil)
-	if err != nil {
-		logging.Log.Errorf("Error creating request for service %s. Error: %v", c.ServiceName, err)
-		return nil, err
-	}
+	serviceName := "CLUSTER_DATA" // Service name used for logging
 
 	// Declare a slice to hold the cluster data
 	var clusters []model.ClusterInfo
-
-	// Make the request and decode response into clusters
-	err = c.Do(req, &clusters)
-	if err != nil {
-		return nil, err
+	seen := make(map[string]bool)
+
+	// Walk the endpoint page by page until a short page is returned or the optional limit is reached
+	for pages := 0; ; pages++ {
+		if pages == c.maxPages() {
+			return nil, fmt.Errorf("stopped fetching clusters after %d pages for service %s", pages, serviceName)
+		}
+
+		pageSize := clusterPageSize
+		if limit > 0 && limit-len(clusters) < pageSize {
+			pageSize = limit - len(clusters)
+		}
+
+		// Construct path parameters
+		pathParams := map[string]string{
+			"limit":     strconv.Itoa(pageSize),
+			"offset":    strconv.Itoa(len(clusters)),
+			"filter":    filter,
+			"connected": connected,
+		}
+
+		// Create URL using the client's method
+		urlFormat, err := c.CreateUrl(clusterInformationPath, pathParams)
+		if err != nil {
+			logging.Log.Errorf("Error creating URL for service %s. Error: %v", serviceName, err)
+			return nil, err
+		}
+
+		// Create a new request
+		req, err := c.NewRequest(ctx, serviceName, http.MethodGet, urlFormat, nil)
+		if err != nil {
+			logging.Log.Errorf("Error creating request for service %s. Error: %v", serviceName, err)
+			return nil, err
+		}
+
+		// Make the request and decode response into the current page
+		var page []model.ClusterInfo
+		err = c.Do(req, &page)
+		if err != nil {
+			return nil, err
+		}
+		logging.Log.Debugf("Fetched %d clusters at offset %d for service %s", len(page), len(clusters), serviceName)
+
+		// A page without new clusters means the offset is not honored, requesting more would loop forever
+		newClusters := 0
+		for _, cluster := range page {
+			// Names are only unique within a cloud account and region
+			key := cluster.Provider + "/" + cluster.AccountID + "/" + cluster.Region + "/" + cluster.Name
+			if !seen[key] {
+				seen[key] = true
+				clusters = append(clusters, cluster)
+				newClusters++
+			}
+		}
+		if len(page) > 0 && newClusters == 0 {
+			logging.Log.Warnf("Page at offset %d brought no new clusters for service %s, stopping pagination", len(clusters), serviceName)
+			break
+		}
+
+		if len(page) < pageSize || (limit > 0 && len(clusters) >= limit) {
+			break
+		}
 	}
 
 	return clusters, nil
 }
 
-func (c *Client) GetAgentData(clusterName string) (model.AgentData, error) {
-
-	c.ServiceName = "AGENT_DATA" // Set the service name for logging
+func (c *Client) GetAgentData(ctx context.Context, clusterName string) (model.AgentData, error) {
 
-	pathParams := map[string]string{"filter": clusterName,
-		// If cluster has multiple nodes, limit 1 might not bring clusters with status we're looking for
-		// such as Up to date, Almost out of date, Out of date
-		"limit":  "500",
-		"offset": "0",
-	}
-
-	// Create URL using the client's method
-	urlFormat, err := c.CreateUrl(agentInformationPath, pathParams)
-	if err != nil {
-		logging.Log.Errorf("Error creating URL for service %s. Error: %v", c.ServiceName, err)
-		return model.AgentData{}, err
-	}
+	serviceName := "AGENT_DATA" // Service name used for logging
 
-	// Create a new request
-	req, err := c.NewRequest(http.MethodGet, urlFormat, nil)
-	if err != nil {
-		logging.Log.Errorf("Error creating request for service %s. Error: %v", c.ServiceName, err)
-		return model.AgentData{}, err
-	}
-
-	// Declare a variable to hold the agent data
+	// Declare a variable to hold the agent data aggregated across pages
 	var agentData model.AgentData
-
-	// Make the request and decode the response into agentData
-	err = c.Do(req, &agentData)
-	if err != nil {
-		return model.AgentData{}, err
+	var previousDetails []model.AgentDetail
+
+	// If cluster has multiple nodes, a single page might not bring agents with status we're looking for
+	// such as Up to date, Almost out of date, Out of date, so walk every page for the cluster
+	for page := 0; ; page++ {
+		if page == c.maxPages() {
+			return model.AgentData{}, fmt.Errorf("stopped fetching agents of cluster %s after %d pages for service %s", clusterName, page, serviceName)
+		}
+		pathParams := map[string]string{"filter": clusterName,
+			"limit":  strconv.Itoa(agentPageSize),
+			"offset": strconv.Itoa(len(agentData.Details)),
+		}
+
+		// Create URL using the client's method
+		urlFormat, err := c.CreateUrl(agentInformationPath, pathParams)
+		if err != nil {
+			logging.Log.Errorf("Error creating URL for service %s. Error: %v", serviceName, err)
+			return model.AgentData{}, err
+		}
+
+		// Create a new request
+		req, err := c.NewRequest(ctx, serviceName, http.MethodGet, urlFormat, nil)
+		if err != nil {
+			logging.Log.Errorf("Error creating request for service %s. Error: %v", serviceName, err)
+			return model.AgentData{}, err
+		}
+
+		// Make the request and decode the response into the current page
+		var pageData model.AgentData
+		err = c.Do(req, &pageData)
+		if err != nil {
+			return model.AgentData{}, err
+		}
+
+		// Stats describe the whole filtered result set, so they are taken from the first page only
+		if page == 0 {
+			agentData.AgentStats = pageData.AgentStats
+		}
+
+		// Agent details carry no identifier, a page repeating the previous one means the offset
+		// is not honored and its details are duplicates
+		if page > 0 && len(pageData.Details) > 0 && reflect.DeepEqual(pageData.Details, previousDetails) {
+			logging.Log.Warnf("Page at offset %d repeated the previous agents of cluster %s for service %s, stopping pagination", len(agentData.Details), clusterName, serviceName)
+			break
+		}
+		previousDetails = pageData.Details
+		agentData.Details = append(agentData.Details, pageData.Details...)
+
+		if len(pageData.Details) < agentPageSize {
+			break
+		}
+		// The total of the first page bounds the walk, details beyond it can only be duplicates
+		if total := agentData.AgentStats.TotalCount; total > 0 && len(agentData.Details) >= total {
+			agentData.Details = agentData.Details[:total]
+			break
+		}
 	}
+	logging.Log.Debugf("Fetched %d agent details for cluster %s", len(agentData.Details), clusterName)
 
 	return agentData, nil
 }
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/logging"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logging.Log = logrus.NewEntry(logger)
+	os.Exit(m.Run())
+}
+
+// newTestClient returns a Client calling server without rate limiting nor retries.
+func newTestClient(server *httptest.Server) *Client {
+	return &Client{BaseURL: server.URL, HTTPClient: server.Client()}
+}
+
+func TestGetAgentDataCombinesPages(t *testing.T) {
+	// Two full pages then a short one, each page reporting different stats
+	pageSizes := []int{agentPageSize, agentPageSize, 42}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+		if err != nil {
+			t.Errorf("invalid offset %q", r.URL.Query().Get("offset"))
+		}
+		page := offset / agentPageSize
+		requests++
+
+		data := model.AgentData{AgentStats: model.AgentStats{TotalCount: 2*agentPageSize + 42 - page, HealthyCount: page + 1}}
+		if page < len(pageSizes) {
+			data.Details = agentPage(page)[:pageSizes[page]]
+		}
+		json.NewEncoder(w).Encode(data)
+	}))
+	defer server.Close()
+
+	agentData, err := newTestClient(server).GetAgentData(context.Background(), "cluster")
+	if err != nil {
+		t.Fatalf("GetAgentData returned an error: %v", err)
+	}
+
+	if want := 2*agentPageSize + 42; len(agentData.Details) != want {
+		t.Errorf("got %d agent details, want %d", len(agentData.Details), want)
+	}
+	if requests != len(pageSizes) {
+		t.Errorf("got %d requests, want %d", requests, len(pageSizes))
+	}
+	if want := (model.AgentStats{TotalCount: 2*agentPageSize + 42, HealthyCount: 1}); agentData.AgentStats != want {
+		t.Errorf("got agent stats %+v, want the first page stats %+v", agentData.AgentStats, want)
+	}
+}
+
+func TestGetClusterDataStopsWhenOffsetIsIgnored(t *testing.T) {
+	// Every request returns the same full page, whatever the offset
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		clusters := make([]model.ClusterInfo, clusterPageSize)
+		for i := range clusters {
+			clusters[i].Name = "cluster-" + strconv.Itoa(i)
+		}
+		json.NewEncoder(w).Encode(clusters)
+	}))
+	defer server.Close()
+
+	clusters, err := newTestClient(server).GetClusterData(context.Background(), 0, "", "")
+	if err != nil {
+		t.Fatalf("GetClusterData returned an error: %v", err)
+	}
+	if len(clusters) != clusterPageSize {
+		t.Errorf("got %d clusters, want %d", len(clusters), clusterPageSize)
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
+
+// agentPage returns a full page of agent details, all last seen at the given second.
+func agentPage(second int) []model.AgentDetail {
+	details := make([]model.AgentDetail, agentPageSize)
+	for i := range details {
+		details[i].AgentLastSeen = "2024-01-01T00:00:" + fmt.Sprintf("%02d", second) + "Z"
+	}
+	return details
+}
+
+func TestGetAgentDataStopsWhenOffsetIsIgnored(t *testing.T) {
+	// Every request returns the same full page, whatever the offset
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(model.AgentData{AgentStats: model.AgentStats{TotalCount: agentPageSize + 1}, Details: agentPage(0)})
+	}))
+	defer server.Close()
+
+	agentData, err := newTestClient(server).GetAgentData(context.Background(), "cluster")
+	if err != nil {
+		t.Fatalf("GetAgentData returned an error: %v", err)
+	}
+	if len(agentData.Details) != agentPageSize {
+		t.Errorf("got %d agent details, want the %d of the repeated page", len(agentData.Details), agentPageSize)
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
+
+func TestGetAgentDataTruncatedToTotalCount(t *testing.T) {
+	// Pages differ but the offset is ignored, so details beyond the total are duplicates
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(model.AgentData{AgentStats: model.AgentStats{TotalCount: agentPageSize + 1}, Details: agentPage(requests)})
+	}))
+	defer server.Close()
+
+	agentData, err := newTestClient(server).GetAgentData(context.Background(), "cluster")
+	if err != nil {
+		t.Fatalf("GetAgentData returned an error: %v", err)
+	}
+	if want := agentPageSize + 1; len(agentData.Details) != want {
+		t.Errorf("got %d agent details, want %d", len(agentData.Details), want)
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
+
+func TestGetAgentDataStopsAfterMaxPages(t *testing.T) {
+	// Distinct full pages without stats never end on their own
+	const maxPages = 5
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(model.AgentData{Details: agentPage(requests)})
+	}))
+	defer server.Close()
+
+	c := newTestClient(server)
+	c.MaxPages = maxPages
+	if _, err := c.GetAgentData(context.Background(), "cluster"); err == nil {
+		t.Error("GetAgentData returned no error after the maximum number of pages")
+	}
+	if requests != maxPages {
+		t.Errorf("got %d requests, want %d", requests, maxPages)
+	}
+}
+
+func TestGetRuntimeDataStopsOnRepeatedCursor(t *testing.T) {
+	// The cursor alternates between two values, so pages repeat forever
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		next := "a"
+		if r.URL.Query().Get("cursor") == "a" {
+			next = "b"
+		}
+		json.NewEncoder(w).Encode(model.RuntimeData{Page: model.Page{Matched: 1, Next: next}, Data: []model.RuntimeResult{{ResultId: "result"}}})
+	}))
+	defer server.Close()
+
+	if _, err := newTestClient(server).GetRuntimeData(context.Background(), "cluster", true); err == nil {
+		t.Error("GetRuntimeData returned no error for a repeated cursor")
+	}
+	if requests != 3 {
+		t.Errorf("got %d requests, want 3", requests)
+	}
+}
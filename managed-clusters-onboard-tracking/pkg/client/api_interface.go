@@ -1,9 +1,12 @@
 package client
 
-import "IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"context"
+)
 
 type API interface {
-	GetRuntimeData(clusterName string) (model.RuntimeCluster, error)
-	GetClusterData(limit int, filter, connected string) ([]model.ClusterInfo, error)
-	GetAgentData(clusterName string) (model.AgentData, error)
+	GetRuntimeData(ctx context.Context, clusterName string, allPages bool) (model.RuntimeCluster, error)
+	GetClusterData(ctx context.Context, limit int, filter, connected string) ([]model.ClusterInfo, error)
+	GetAgentData(ctx context.Context, clusterName string) (model.AgentData, error)
 }
@@ -1,30 +1,150 @@
 package client
 
 import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/config"
 	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/logging"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/cenkalti/backoff/v4"
+	"golang.org/x/time/rate"
 	"io"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 )
 
+// Client is safe for concurrent use: it holds no per-call state and shares a single http.Client.
 type Client struct {
-	ServiceName    string
 	BaseURL        string
 	SecureApiToken string
 	MaxRetries     int
+	MaxElapsedTime time.Duration
+	MaxInterval    time.Duration
+	HTTPClient     *http.Client
+	// RateLimiter is shared by every call, a nil limiter disables client-side rate limiting
+	RateLimiter *rate.Limiter
+	// MaxPages bounds the pages requested by a single paginated call, 0 uses defaultMaxPages
+	MaxPages int
+
+	rateMutex sync.Mutex
+	// maxRateLimit is the configured rate the limiter recovers to after being reduced
+	maxRateLimit  float64
+	lastReduction time.Time
+	successes     int
 }
 
+// serviceNameKey is the request context key holding the service name used for logging.
+type serviceNameKey struct{}
+
 const (
-	maxElapsedTime = 10
-	maxInterval    = 500
+	defaultMaxElapsedTime = 10
+	defaultMaxInterval    = 500
+
+	// Connection pool settings for the shared transport
+	maxIdleConnsPerHost = 100
+
+	// Rate is multiplied by this factor on a 429, never going below minRateLimit requests per second.
+	// Throttled responses within rateLimitReductionWindow of the last reduction count as the same burst.
+	rateLimitBackoffFactor   = 0.5
+	minRateLimit             = 0.5
+	rateLimitReductionWindow = 2 * time.Second
+
+	// Rate is multiplied by this factor after rateRecoverySuccesses successful requests in a row,
+	// never going above the configured rate
+	rateLimitRecoveryFactor = 1.25
+	rateRecoverySuccesses   = 50
 )
 
-func (c *Client) NewRequest(method string, url *url.URL, body io.Reader) (*http.Request, error) {
-	req, err := http.NewRequest(method, url.String(), body)
+// NewClient creates a Client with its own configured http.Client, reusing connections across calls.
+func NewClient(cfg *config.Configuration) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxIdleConnsPerHost
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+
+	c := &Client{
+		BaseURL:        cfg.ApiURL,
+		SecureApiToken: cfg.SecureApiToken,
+		MaxRetries:     cfg.ApiMaxRetries,
+		MaxElapsedTime: time.Duration(cfg.ApiMaxElapsedTime) * time.Second,
+		MaxInterval:    time.Duration(cfg.ApiMaxInterval) * time.Millisecond,
+		HTTPClient:     &http.Client{Transport: transport, Timeout: time.Duration(cfg.ApiRequestTimeout) * time.Second},
+	}
+	if cfg.ApiRateLimit > 0 {
+		c.RateLimiter = rate.NewLimiter(rate.Limit(cfg.ApiRateLimit), cfg.ApiRateBurst)
+		c.maxRateLimit = cfg.ApiRateLimit
+	}
+	return c
+}
+
+// EffectiveRateLimit returns the current requests per second allowed by the client, 0 when unlimited.
+func (c *Client) EffectiveRateLimit() float64 {
+	if c.RateLimiter == nil {
+		return 0
+	}
+	return float64(c.RateLimiter.Limit())
+}
+
+// reduceRateLimit lowers the rate after the API throttled us, keeping it above minRateLimit.
+// The rate is reduced at most once per rateLimitReductionWindow, so a burst of throttled concurrent calls
+// only halves it once.
+func (c *Client) reduceRateLimit() {
+	if c.RateLimiter == nil {
+		return
+	}
+	c.rateMutex.Lock()
+	defer c.rateMutex.Unlock()
+
+	current := float64(c.RateLimiter.Limit())
+	if c.maxRateLimit == 0 {
+		c.maxRateLimit = current
+	}
+	c.successes = 0
+	if time.Since(c.lastReduction) < rateLimitReductionWindow {
+		return
+	}
+	c.lastReduction = time.Now()
+
+	reduced := current * rateLimitBackoffFactor
+	if reduced < minRateLimit {
+		reduced = minRateLimit
+	}
+	if reduced < current {
+		c.RateLimiter.SetLimit(rate.Limit(reduced))
+		logging.Log.Warnf("Reduced API rate limit from %.2f to %.2f requests per second", current, reduced)
+	}
+}
+
+// recoverRateLimit raises a reduced rate back toward the configured one after a run of successful requests.
+func (c *Client) recoverRateLimit() {
+	if c.RateLimiter == nil {
+		return
+	}
+	c.rateMutex.Lock()
+	defer c.rateMutex.Unlock()
+
+	current := float64(c.RateLimiter.Limit())
+	if c.maxRateLimit == 0 || current >= c.maxRateLimit {
+		return
+	}
+	c.successes++
+	if c.successes < rateRecoverySuccesses {
+		return
+	}
+	c.successes = 0
+
+	raised := current * rateLimitRecoveryFactor
+	if raised > c.maxRateLimit {
+		raised = c.maxRateLimit
+	}
+	c.RateLimiter.SetLimit(rate.Limit(raised))
+	logging.Log.Infof("Raised API rate limit from %.2f to %.2f requests per second", current, raised)
+}
+
+func (c *Client) NewRequest(ctx context.Context, serviceName, method string, url *url.URL, body io.Reader) (*http.Request, error) {
+	ctx = context.WithValue(ctx, serviceNameKey{}, serviceName)
+	req, err := http.NewRequestWithContext(ctx, method, url.String(), body)
 	if err != nil {
 		return nil, err
 	}
@@ -48,36 +168,85 @@ func (c *Client) CreateUrl(path string, pathParams map[string]string) (*url.URL,
 
 func (c *Client) Do(req *http.Request, v interface{}) error {
 	attempt := 0
+	serviceName, _ := req.Context().Value(serviceNameKey{}).(string)
+	retryable := isIdempotent(req.Method)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	// Exponential backoff configuration
+	expBackOff := backoff.NewExponentialBackOff()
+	expBackOff.MaxElapsedTime = c.maxElapsedTime()
+	expBackOff.MaxInterval = c.maxInterval()
+	retryBackOff := &retryAfterBackOff{BackOff: backoff.WithMaxRetries(expBackOff, uint64(c.MaxRetries)), maxDelay: c.maxElapsedTime()}
 
 	operation := func() error {
 		attempt++
-		resp, err := http.DefaultClient.Do(req)
+		if c.RateLimiter != nil {
+			if err := c.RateLimiter.Wait(req.Context()); err != nil {
+				return backoff.Permanent(err)
+			}
+		}
+		resp, err := httpClient.Do(req)
 		if err != nil {
+			if req.Context().Err() != nil || !retryable {
+				return backoff.Permanent(err)
+			}
+			logging.Log.Errorf("Attempt %d calling API %s for service %s: %v. Retrying...", attempt, req.URL, serviceName, err)
 			return err
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode == http.StatusOK {
-			logging.Log.Debugf("successfully called endpoint %s with status code %d", req.URL, resp.StatusCode)
+			c.recoverRateLimit()
+			logging.Log.Debugf("successfully called endpoint %s for service %s with status code %d", req.URL, serviceName, resp.StatusCode)
 			if v != nil {
-				return json.NewDecoder(resp.Body).Decode(v)
+				if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+					return backoff.Permanent(err)
+				}
 			}
 			return nil
 		}
 
-		if resp.StatusCode == http.StatusTooManyRequests && attempt < c.MaxRetries {
-			logging.Log.Errorf("Attempt %d calling API %s: Received status code 429. Retrying...", attempt, req.URL)
-			return fmt.Errorf("you were trothled in API %s. status code: %d", req.URL, resp.StatusCode)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			c.reduceRateLimit()
+		}
+
+		if retryable && isRetryableStatus(resp.StatusCode) && attempt <= c.MaxRetries {
+			retryBackOff.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			logging.Log.Errorf("Attempt %d calling API %s for service %s: Received status code %d. Retrying...", attempt, req.URL, serviceName, resp.StatusCode)
+			if resp.StatusCode == http.StatusTooManyRequests {
+				return fmt.Errorf("you were trothled in API %s. status code: %d", req.URL, resp.StatusCode)
+			}
+			return fmt.Errorf("server error in API %s. status code: %d", req.URL, resp.StatusCode)
 		}
 
 		return backoff.Permanent(fmt.Errorf("status code: %d", resp.StatusCode))
 	}
 
-	// Exponential backoff configuration
-	expBackOff := backoff.NewExponentialBackOff()
-	expBackOff.MaxElapsedTime = maxElapsedTime * time.Second
-	expBackOff.MaxInterval = maxInterval * time.Millisecond
+	// Retry with exponential backoff, honoring Retry-After when present and stopping when the request is cancelled
+	return backoff.Retry(operation, backoff.WithContext(retryBackOff, req.Context()))
+}
+
+func (c *Client) maxElapsedTime() time.Duration {
+	if c.MaxElapsedTime > 0 {
+		return c.MaxElapsedTime
+	}
+	return defaultMaxElapsedTime * time.Second
+}
+
+func (c *Client) maxPages() int {
+	if c.MaxPages > 0 {
+		return c.MaxPages
+	}
+	return defaultMaxPages
+}
 
-	// Retry with exponential backoff
-	return backoff.Retry(operation, backoff.WithMaxRetries(expBackOff, uint64(c.MaxRetries)))
+func (c *Client) maxInterval() time.Duration {
+	if c.MaxInterval > 0 {
+		return c.MaxInterval
+	}
+	return defaultMaxInterval * time.Millisecond
 }
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/time/rate"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientConcurrentCalls(t *testing.T) {
+	const (
+		callers    = 50
+		throttled  = 3
+		rateLimit  = 1000
+		maxRetries = 3
+	)
+
+	// The first requests are throttled so concurrent calls also lower the shared rate limit
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= throttled {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprintf(w, `{"path": %q}`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	c := newTestClient(server)
+	c.MaxRetries = maxRetries
+	c.MaxInterval = 10 * time.Millisecond
+	c.RateLimiter = rate.NewLimiter(rateLimit, rateLimit)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			serviceName := fmt.Sprintf("SERVICE_%d", i)
+			url, err := c.CreateUrl("%s/"+serviceName, map[string]string{"page": fmt.Sprint(i)})
+			if err != nil {
+				errs <- err
+				return
+			}
+			req, err := c.NewRequest(context.Background(), serviceName, http.MethodGet, url, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if got, _ := req.Context().Value(serviceNameKey{}).(string); got != serviceName {
+				errs <- fmt.Errorf("request carries service name %q, want %q", got, serviceName)
+				return
+			}
+
+			var response struct {
+				Path string `json:"path"`
+			}
+			if err := c.Do(req, &response); err != nil {
+				errs <- fmt.Errorf("%s: %w", serviceName, err)
+				return
+			}
+			if response.Path != "/"+serviceName {
+				errs <- fmt.Errorf("%s got the response of %s", serviceName, response.Path)
+			}
+			c.EffectiveRateLimit()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if got := c.EffectiveRateLimit(); got >= rateLimit {
+		t.Errorf("rate limit is %v after throttled requests, want it below %v", got, float64(rateLimit))
+	}
+}
+
+func TestRetryAfterCappedAtMaxElapsedTime(t *testing.T) {
+	// The API asks for an hour, far beyond the retry budget of the client
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	c := newTestClient(server)
+	c.MaxRetries = 1
+	c.MaxElapsedTime = time.Second
+
+	url, err := c.CreateUrl("%s/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := c.NewRequest(context.Background(), "SERVICE", http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := c.Do(req, nil); err != nil {
+		t.Fatalf("Do returned an error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*c.MaxElapsedTime {
+		t.Errorf("Do waited %v for the Retry-After delay, want at most about %v", elapsed, c.MaxElapsedTime)
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
+
+func TestRateLimitReducedOncePerBurstAndRecovered(t *testing.T) {
+	const rateLimit = 10
+
+	c := &Client{RateLimiter: rate.NewLimiter(rateLimit, rateLimit)}
+
+	// A burst of throttled responses only halves the rate once
+	for i := 0; i < 10; i++ {
+		c.reduceRateLimit()
+	}
+	if got, want := c.EffectiveRateLimit(), rateLimit*rateLimitBackoffFactor; got != want {
+		t.Fatalf("rate limit is %v after a burst of throttled responses, want %v", got, want)
+	}
+
+	// Successful requests raise it back up to the configured rate, never above
+	for i := 0; i < 10*rateRecoverySuccesses; i++ {
+		c.recoverRateLimit()
+	}
+	if got := c.EffectiveRateLimit(); got != rateLimit {
+		t.Errorf("rate limit is %v after successful requests, want %v", got, float64(rateLimit))
+	}
+}
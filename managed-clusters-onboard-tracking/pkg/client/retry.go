@@ -0,0 +1,73 @@
+package client
+
+import (
+	"github.com/cenkalti/backoff/v4"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryAfterBackOff waits for the delay requested by the API through the Retry-After header
+// when there is one, and falls back to the wrapped policy otherwise. The requested delay is
+// capped at maxDelay so a large Retry-After cannot stall a lookup past the retry budget.
+type retryAfterBackOff struct {
+	backoff.BackOff
+	retryAfter time.Duration
+	maxDelay   time.Duration
+}
+
+func (b *retryAfterBackOff) NextBackOff() time.Duration {
+	next := b.BackOff.NextBackOff()
+	if next == backoff.Stop {
+		return next
+	}
+	if b.retryAfter > 0 {
+		next = b.retryAfter
+		if b.maxDelay > 0 && next > b.maxDelay {
+			next = b.maxDelay
+		}
+		b.retryAfter = 0
+	}
+	return next
+}
+
+// parseRetryAfter reads a Retry-After header expressed either in seconds or as an HTTP date.
+// It returns 0 when the header is missing or invalid.
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// isRetryableStatus reports whether a response status is worth retrying for an idempotent request.
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// isIdempotent reports whether a request can be safely sent again after a failure.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
@@ -13,6 +13,28 @@ type Configuration struct {
 	ApiURL         string
 	SecureApiToken string
 	ApiMaxRetries  int
+	// Timeout in seconds of every single HTTP request, 0 disables it
+	ApiRequestTimeout int
+	// Retry backoff bounds, in seconds and milliseconds respectively
+	ApiMaxElapsedTime int
+	ApiMaxInterval    int
+	// Concurrency caps in-flight enrichment requests, per-endpoint limits of 0 fall back to it
+	Concurrency        int
+	AgentConcurrency   int
+	RuntimeConcurrency int
+	// Client-side token bucket for Sysdig API calls, a rate of 0 disables it
+	ApiRateLimit float64
+	ApiRateBurst int
+	// Minutes without report after which an agent is stale, 0 disables stale detection
+	StaleAgentWindow int
+	// Optional JSON file with cluster classification rules
+	ClassificationRulesFile string
+	AgentVersionPolicyFile  string
+	// Address the serve command listens on and minutes between two data collections
+	ListenAddress   string
+	RefreshInterval int
+	// Optional SQLite database where every collection is stored as a snapshot
+	SnapshotDB string
 }
 
 var Config *Configuration
@@ -27,12 +49,44 @@ func LoadConfig() []error {
 		ApiURL:         getEnv("API_URL", "https://secure.sysdig.com"),
 		SecureApiToken: getEnv("SECURE_API_TOKEN", ""),
 		ApiMaxRetries:  getIntEnv("API_MAX_RETRIES", 3),
+
+		ApiRequestTimeout: getIntEnv("API_REQUEST_TIMEOUT", 30),
+
+		ApiMaxElapsedTime: getIntEnv("API_MAX_ELAPSED_TIME", 10),
+		ApiMaxInterval:    getIntEnv("API_MAX_INTERVAL", 500),
+
+		Concurrency:        getIntEnv("CONCURRENCY", 10),
+		AgentConcurrency:   getIntEnv("AGENT_CONCURRENCY", 0),
+		RuntimeConcurrency: getIntEnv("RUNTIME_CONCURRENCY", 0),
+
+		ApiRateLimit: getFloatEnv("API_RATE_LIMIT", 10),
+		ApiRateBurst: getIntEnv("API_RATE_BURST", 10),
+
+		StaleAgentWindow: getIntEnv("STALE_AGENT_WINDOW", 60),
+
+		ClassificationRulesFile: getEnv("CLASSIFICATION_RULES_FILE", ""),
+		AgentVersionPolicyFile:  getEnv("AGENT_VERSION_POLICY_FILE", ""),
+		ListenAddress:           getEnv("LISTEN_ADDRESS", ":8080"),
+		RefreshInterval:         getIntEnv("REFRESH_INTERVAL", 60),
+		SnapshotDB:              getEnv("SNAPSHOT_DB", ""),
 	}
 
 	if Config.ServiceName == "" {
 		errs = append(errs, fmt.Errorf("SERVICE_NAME is missing"))
 	}
 
+	if Config.Concurrency < 1 {
+		errs = append(errs, fmt.Errorf("CONCURRENCY must be greater than zero"))
+	}
+
+	if Config.StaleAgentWindow < 0 {
+		errs = append(errs, fmt.Errorf("STALE_AGENT_WINDOW must not be negative"))
+	}
+
+	if Config.ApiRateLimit > 0 && Config.ApiRateBurst < 1 {
+		errs = append(errs, fmt.Errorf("API_RATE_BURST must be greater than zero when API_RATE_LIMIT is set"))
+	}
+
 	return errs
 }
 
@@ -48,7 +102,19 @@ func getIntEnv(key string, defaultVal int) int {
 	if exists {
 		value, err := strconv.Atoi(value)
 		if err != nil {
-			log.Fatalf("Error converting env variable %s to integer. Please provide a integer convertable type.")
+			log.Fatalf("Error converting env variable %s to integer. Please provide a integer convertable type.", key)
+		}
+		return value
+	}
+	return defaultVal
+}
+
+func getFloatEnv(key string, defaultVal float64) float64 {
+	value, exists := os.LookupEnv(key)
+	if exists {
+		value, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Fatalf("Error converting env variable %s to float. Please provide a float convertable type.", key)
 		}
 		return value
 	}
@@ -0,0 +1,237 @@
+package diff
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/version"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Change describes a value of a cluster that differs between two runs.
+type Change struct {
+	Cluster string `json:"cluster"`
+	Before  string `json:"before"`
+	After   string `json:"after"`
+}
+
+// Report lists the differences between two runs, each list sorted by cluster name.
+type Report struct {
+	Added             []string `json:"added"`
+	Removed           []string `json:"removed"`
+	NewlyConnected    []string `json:"newly_connected"`
+	NewlyDisconnected []string `json:"newly_disconnected"`
+	AgentUpgrades     []Change `json:"agent_upgrades"`
+	AgentDowngrades   []Change `json:"agent_downgrades"`
+	RuntimeEnabled    []string `json:"runtime_enabled"`
+	RuntimeDisabled   []string `json:"runtime_disabled"`
+	NodeCountChanges  []Change `json:"node_count_changes"`
+}
+
+// Compare returns what changed from the before run to the after run. Clusters are matched by provider,
+// account, region and name when both runs have the account and region, as snapshots do, and by
+// provider and name otherwise, as reports do not keep them. A cluster appearing twice in a run is an error.
+func Compare(before, after []model.ClusterWithAgentMetadata) (Report, error) {
+	report := Report{
+		Added:             []string{},
+		Removed:           []string{},
+		NewlyConnected:    []string{},
+		NewlyDisconnected: []string{},
+		AgentUpgrades:     []Change{},
+		AgentDowngrades:   []Change{},
+		RuntimeEnabled:    []string{},
+		RuntimeDisabled:   []string{},
+		NodeCountChanges:  []Change{},
+	}
+
+	keys := newClusterKeys(before, after)
+	previous, err := keys.index("before", before)
+	if err != nil {
+		return Report{}, err
+	}
+	current, err := keys.index("after", after)
+	if err != nil {
+		return Report{}, err
+	}
+
+	for _, cluster := range after {
+		old, ok := previous[keys.key(cluster)]
+		name := keys.displayName(cluster)
+		if !ok {
+			report.Added = append(report.Added, name)
+			continue
+		}
+
+		if !old.AgentConnected && cluster.AgentConnected {
+			report.NewlyConnected = append(report.NewlyConnected, name)
+		}
+		if old.AgentConnected && !cluster.AgentConnected {
+			report.NewlyDisconnected = append(report.NewlyDisconnected, name)
+		}
+
+		versionChange := Change{Cluster: name, Before: old.AgentVersion, After: cluster.AgentVersion}
+		switch compareVersions(old.AgentVersion, cluster.AgentVersion) {
+		case 1:
+			report.AgentUpgrades = append(report.AgentUpgrades, versionChange)
+		case -1:
+			report.AgentDowngrades = append(report.AgentDowngrades, versionChange)
+		}
+
+		if !old.RuntimeEnabled && cluster.RuntimeEnabled {
+			report.RuntimeEnabled = append(report.RuntimeEnabled, name)
+		}
+		if old.RuntimeEnabled && !cluster.RuntimeEnabled {
+			report.RuntimeDisabled = append(report.RuntimeDisabled, name)
+		}
+
+		if old.NodeCount != cluster.NodeCount {
+			report.NodeCountChanges = append(report.NodeCountChanges, Change{
+				Cluster: name,
+				Before:  strconv.Itoa(old.NodeCount),
+				After:   strconv.Itoa(cluster.NodeCount),
+			})
+		}
+	}
+
+	for _, cluster := range before {
+		if _, ok := current[keys.key(cluster)]; !ok {
+			report.Removed = append(report.Removed, keys.displayName(cluster))
+		}
+	}
+
+	report.sort()
+	return report, nil
+}
+
+// clusterKeys identifies the clusters of the two compared runs.
+type clusterKeys struct {
+	// withLocation is set when both runs have the account and region of their clusters
+	withLocation bool
+	// sharedNames holds the names used by more than one cluster of a run
+	sharedNames map[string]bool
+}
+
+func newClusterKeys(before, after []model.ClusterWithAgentMetadata) clusterKeys {
+	keys := clusterKeys{withLocation: hasLocation(before) && hasLocation(after), sharedNames: make(map[string]bool)}
+	for _, run := range [][]model.ClusterWithAgentMetadata{before, after} {
+		seen := make(map[string]bool, len(run))
+		for _, cluster := range run {
+			if seen[cluster.Name] {
+				keys.sharedNames[cluster.Name] = true
+			}
+			seen[cluster.Name] = true
+		}
+	}
+	return keys
+}
+
+// hasLocation reports whether the clusters of a run carry their account or region.
+func hasLocation(clusters []model.ClusterWithAgentMetadata) bool {
+	for _, cluster := range clusters {
+		if cluster.AccountID != "" || cluster.Region != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (k clusterKeys) key(cluster model.ClusterWithAgentMetadata) string {
+	if k.withLocation {
+		return cluster.Provider + "/" + cluster.AccountID + "/" + cluster.Region + "/" + cluster.Name
+	}
+	return cluster.Provider + "/" + cluster.Name
+}
+
+// displayName returns the cluster name, qualified by its key when other clusters share the name.
+func (k clusterKeys) displayName(cluster model.ClusterWithAgentMetadata) string {
+	if k.sharedNames[cluster.Name] {
+		return fmt.Sprintf("%s (%s)", cluster.Name, k.key(cluster))
+	}
+	return cluster.Name
+}
+
+// index maps the clusters of a run by key, failing when two clusters have the same key.
+func (k clusterKeys) index(run string, clusters []model.ClusterWithAgentMetadata) (map[string]model.ClusterWithAgentMetadata, error) {
+	indexed := make(map[string]model.ClusterWithAgentMetadata, len(clusters))
+	for _, cluster := range clusters {
+		key := k.key(cluster)
+		if _, ok := indexed[key]; ok {
+			return nil, fmt.Errorf("cluster %s appears more than once in the %s run", key, run)
+		}
+		indexed[key] = cluster
+	}
+	return indexed, nil
+}
+
+// compareVersions returns 1 for an upgrade, -1 for a downgrade and 0 when unchanged or not comparable,
+// such as when a cluster had no agent version in one of the runs.
+func compareVersions(before, after string) int {
+	beforeVersion, err := version.Parse(before)
+	if err != nil {
+		return 0
+	}
+	afterVersion, err := version.Parse(after)
+	if err != nil {
+		return 0
+	}
+	return afterVersion.Compare(beforeVersion)
+}
+
+// IsEmpty reports whether the two runs are equivalent.
+func (r Report) IsEmpty() bool {
+	return len(r.Added)+len(r.Removed)+len(r.NewlyConnected)+len(r.NewlyDisconnected)+len(r.AgentUpgrades)+
+		len(r.AgentDowngrades)+len(r.RuntimeEnabled)+len(r.RuntimeDisabled)+len(r.NodeCountChanges) == 0
+}
+
+func (r *Report) sort() {
+	for _, names := range [][]string{r.Added, r.Removed, r.NewlyConnected, r.NewlyDisconnected, r.RuntimeEnabled, r.RuntimeDisabled} {
+		sort.Strings(names)
+	}
+	for _, changes := range [][]Change{r.AgentUpgrades, r.AgentDowngrades, r.NodeCountChanges} {
+		changes := changes
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Cluster < changes[j].Cluster })
+	}
+}
+
+// WriteText writes the report in a human readable form.
+func (r Report) WriteText(w io.Writer) error {
+	sections := []struct {
+		title   string
+		names   []string
+		changes []Change
+	}{
+		{title: "Added clusters", names: r.Added},
+		{title: "Removed clusters", names: r.Removed},
+		{title: "Newly connected clusters", names: r.NewlyConnected},
+		{title: "Newly disconnected clusters", names: r.NewlyDisconnected},
+		{title: "Agent version upgrades", changes: r.AgentUpgrades},
+		{title: "Agent version downgrades", changes: r.AgentDowngrades},
+		{title: "Runtime enabled", names: r.RuntimeEnabled},
+		{title: "Runtime disabled", names: r.RuntimeDisabled},
+		{title: "Node count changes", changes: r.NodeCountChanges},
+	}
+
+	if r.IsEmpty() {
+		_, err := fmt.Fprintln(w, "No changes between the two runs.")
+		return err
+	}
+
+	for _, section := range sections {
+		count := len(section.names) + len(section.changes)
+		if count == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s (%d):\n", section.title, count); err != nil {
+			return err
+		}
+		for _, name := range section.names {
+			fmt.Fprintf(w, "  - %s\n", name)
+		}
+		for _, change := range section.changes {
+			fmt.Fprintf(w, "  - %s: %s -> %s\n", change.Cluster, change.Before, change.After)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package diff
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"reflect"
+	"testing"
+)
+
+func cluster(name, account, agentVersion string, connected, runtime bool, nodes int) model.ClusterWithAgentMetadata {
+	return model.ClusterWithAgentMetadata{
+		ClusterInfo:    model.ClusterInfo{Name: name, Provider: "aws", AccountID: account, NodeCount: nodes, AgentConnected: connected},
+		AgentVersion:   agentVersion,
+		RuntimeEnabled: runtime,
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name   string
+		before []model.ClusterWithAgentMetadata
+		after  []model.ClusterWithAgentMetadata
+		want   Report
+	}{
+		{
+			name:   "added and removed",
+			before: []model.ClusterWithAgentMetadata{cluster("a", "1", "N/A", false, false, 1), cluster("b", "1", "N/A", false, false, 1)},
+			after:  []model.ClusterWithAgentMetadata{cluster("b", "1", "N/A", false, false, 1), cluster("c", "1", "N/A", false, false, 1)},
+			want:   Report{Added: []string{"c"}, Removed: []string{"a"}},
+		},
+		{
+			name:   "connection, runtime and node count",
+			before: []model.ClusterWithAgentMetadata{cluster("a", "1", "N/A", false, true, 1), cluster("b", "1", "N/A", true, false, 2)},
+			after:  []model.ClusterWithAgentMetadata{cluster("a", "1", "N/A", true, false, 3), cluster("b", "1", "N/A", false, true, 2)},
+			want: Report{
+				NewlyConnected:    []string{"a"},
+				NewlyDisconnected: []string{"b"},
+				RuntimeEnabled:    []string{"b"},
+				RuntimeDisabled:   []string{"a"},
+				NodeCountChanges:  []Change{{Cluster: "a", Before: "1", After: "3"}},
+			},
+		},
+		{
+			name:   "versions are compared numerically",
+			before: []model.ClusterWithAgentMetadata{cluster("a", "1", "12.9.0", true, false, 1), cluster("b", "1", "13.0.0", true, false, 1), cluster("c", "1", "N/A", false, false, 1)},
+			after:  []model.ClusterWithAgentMetadata{cluster("a", "1", "12.10.0", true, false, 1), cluster("b", "1", "12.16.1", true, false, 1), cluster("c", "1", "13.0.0", true, false, 1)},
+			want: Report{
+				NewlyConnected:  []string{"c"},
+				AgentUpgrades:   []Change{{Cluster: "a", Before: "12.9.0", After: "12.10.0"}},
+				AgentDowngrades: []Change{{Cluster: "b", Before: "13.0.0", After: "12.16.1"}},
+			},
+		},
+		{
+			name:   "same name in different accounts",
+			before: []model.ClusterWithAgentMetadata{cluster("prod", "1", "12.9.0", true, false, 1), cluster("prod", "2", "12.9.0", true, false, 1)},
+			after:  []model.ClusterWithAgentMetadata{cluster("prod", "1", "12.10.0", true, false, 1), cluster("prod", "3", "12.9.0", true, false, 1)},
+			want: Report{
+				Added:         []string{"prod (aws/3//prod)"},
+				Removed:       []string{"prod (aws/2//prod)"},
+				AgentUpgrades: []Change{{Cluster: "prod (aws/1//prod)", Before: "12.9.0", After: "12.10.0"}},
+			},
+		},
+		{
+			name:   "reports without account match by provider and name",
+			before: []model.ClusterWithAgentMetadata{cluster("a", "", "N/A", false, false, 1)},
+			after:  []model.ClusterWithAgentMetadata{cluster("a", "1", "N/A", true, false, 1)},
+			want:   Report{NewlyConnected: []string{"a"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Compare(test.before, test.after)
+			if err != nil {
+				t.Fatalf("Compare returned an error: %v", err)
+			}
+			if !reflect.DeepEqual(normalize(got), normalize(test.want)) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCompareRejectsDuplicateClusters(t *testing.T) {
+	duplicated := []model.ClusterWithAgentMetadata{cluster("a", "1", "N/A", false, false, 1), cluster("a", "1", "N/A", false, false, 2)}
+	if _, err := Compare(duplicated, nil); err == nil {
+		t.Error("Compare returned no error for a cluster appearing twice")
+	}
+}
+
+// normalize replaces empty lists with nil so expected reports only list what changed.
+func normalize(r Report) Report {
+	for _, names := range []*[]string{&r.Added, &r.Removed, &r.NewlyConnected, &r.NewlyDisconnected, &r.RuntimeEnabled, &r.RuntimeDisabled} {
+		if len(*names) == 0 {
+			*names = nil
+		}
+	}
+	for _, changes := range []*[]Change{&r.AgentUpgrades, &r.AgentDowngrades, &r.NodeCountChanges} {
+		if len(*changes) == 0 {
+			*changes = nil
+		}
+	}
+	return r
+}
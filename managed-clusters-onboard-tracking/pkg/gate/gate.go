@@ -0,0 +1,179 @@
+package gate
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/classifier"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Disabled turns off a numeric threshold.
+const Disabled = -1
+
+// Thresholds are the compliance checks evaluated against the collected data.
+type Thresholds struct {
+	// MinNodeCoverage is the minimum percentage of nodes with a connected agent
+	MinNodeCoverage float64
+	// MaxOutOfDate is the maximum number of clusters with at least one out of date agent
+	MaxOutOfDate int
+	// MaxStale is the maximum number of clusters whose agents stopped reporting
+	MaxStale int
+	// RequireRuntime lists cluster selectors that must all have runtime enabled
+	RequireRuntime Selectors
+}
+
+// NewThresholds returns thresholds with every check disabled.
+func NewThresholds() Thresholds {
+	return Thresholds{MinNodeCoverage: Disabled, MaxOutOfDate: Disabled, MaxStale: Disabled}
+}
+
+// Selector matches clusters whose dimension equals value. Besides classification dimensions,
+// provider and region can be used, and env is a shorthand for environment.
+type Selector struct {
+	Dimension string
+	Value     string
+}
+
+func (s Selector) String() string {
+	return s.Dimension + "=" + s.Value
+}
+
+func (s Selector) matches(cluster model.ClusterWithAgentMetadata) bool {
+	switch s.Dimension {
+	case "provider":
+		return cluster.Provider == s.Value
+	case "region":
+		return cluster.Region == s.Value
+	case "env":
+		return cluster.Dimensions[classifier.EnvironmentDimension] == s.Value
+	default:
+		return cluster.Dimensions[s.Dimension] == s.Value
+	}
+}
+
+// Selectors implements flag.Value so the selector flag can be repeated.
+type Selectors []Selector
+
+func (s *Selectors) String() string {
+	values := make([]string, 0, len(*s))
+	for _, selector := range *s {
+		values = append(values, selector.String())
+	}
+	return strings.Join(values, ",")
+}
+
+func (s *Selectors) Set(value string) error {
+	dimension, expected, ok := strings.Cut(value, "=")
+	if !ok || dimension == "" || expected == "" {
+		return fmt.Errorf("invalid selector %q, expected dimension=value", value)
+	}
+	*s = append(*s, Selector{Dimension: dimension, Value: expected})
+	return nil
+}
+
+// Violation is a failed check with the clusters responsible for it, when it applies to clusters.
+type Violation struct {
+	Check    string
+	Message  string
+	Clusters []string
+}
+
+// Enabled reports whether any check is configured.
+func (t Thresholds) Enabled() bool {
+	return t.MinNodeCoverage != Disabled || t.MaxOutOfDate != Disabled || t.MaxStale != Disabled || len(t.RequireRuntime) > 0
+}
+
+// Evaluate runs every enabled check and returns the violations found. Incomplete clusters are left
+// out of every check, their failed lookups are reported separately.
+func Evaluate(clusters []model.ClusterWithAgentMetadata, thresholds Thresholds) ([]Violation, error) {
+	var violations []Violation
+
+	complete := make([]model.ClusterWithAgentMetadata, 0, len(clusters))
+	for _, cluster := range clusters {
+		if !cluster.Incomplete() {
+			complete = append(complete, cluster)
+		}
+	}
+	clusters = complete
+
+	if thresholds.MinNodeCoverage != Disabled {
+		metrics, err := model.ComputeMetrics(clusters)
+		if err != nil {
+			return nil, err
+		}
+		if metrics.NodeCoverage() < thresholds.MinNodeCoverage {
+			violations = append(violations, Violation{
+				Check:   "min-node-coverage",
+				Message: fmt.Sprintf("node coverage is %.2f%% (%d/%d nodes), below the minimum of %.2f%%", metrics.NodeCoverage(), metrics.TotalNodesConnected, metrics.TotalNodes, thresholds.MinNodeCoverage),
+			})
+		}
+	}
+
+	if thresholds.MaxOutOfDate != Disabled {
+		var outOfDate []string
+		for _, cluster := range clusters {
+			// AgentStatus is the status of the first agent only, the counts cover every node
+			if cluster.AgentStatusCounts[string(model.AgentStatusOutOfDate)] > 0 {
+				outOfDate = append(outOfDate, cluster.Name)
+			}
+		}
+		if len(outOfDate) > thresholds.MaxOutOfDate {
+			violations = append(violations, Violation{
+				Check:    "max-out-of-date",
+				Message:  fmt.Sprintf("%d clusters have at least one out of date agent, above the maximum of %d", len(outOfDate), thresholds.MaxOutOfDate),
+				Clusters: outOfDate,
+			})
+		}
+	}
+
+	if thresholds.MaxStale != Disabled {
+		var stale []string
+		for _, cluster := range clusters {
+			if cluster.Stale {
+				stale = append(stale, cluster.Name)
+			}
+		}
+		if len(stale) > thresholds.MaxStale {
+			violations = append(violations, Violation{
+				Check:    "max-stale",
+				Message:  fmt.Sprintf("%d clusters have stale agents, above the maximum of %d", len(stale), thresholds.MaxStale),
+				Clusters: stale,
+			})
+		}
+	}
+
+	for _, selector := range thresholds.RequireRuntime {
+		var withoutRuntime []string
+		for _, cluster := range clusters {
+			if selector.matches(cluster) && !cluster.RuntimeEnabled {
+				withoutRuntime = append(withoutRuntime, cluster.Name)
+			}
+		}
+		if len(withoutRuntime) > 0 {
+			violations = append(violations, Violation{
+				Check:    "require-runtime " + selector.String(),
+				Message:  fmt.Sprintf("%d clusters matching %s do not have runtime enabled", len(withoutRuntime), selector),
+				Clusters: withoutRuntime,
+			})
+		}
+	}
+
+	return violations, nil
+}
+
+// WriteViolations writes a human readable report of the violations.
+func WriteViolations(w io.Writer, violations []Violation) {
+	if len(violations) == 0 {
+		fmt.Fprintln(w, "All compliance checks passed.")
+		return
+	}
+
+	fmt.Fprintf(w, "%d compliance checks failed:\n", len(violations))
+	for _, violation := range violations {
+		fmt.Fprintf(w, "\n[%s] %s\n", violation.Check, violation.Message)
+		for _, cluster := range violation.Clusters {
+			fmt.Fprintf(w, "  - %s\n", cluster)
+		}
+	}
+}
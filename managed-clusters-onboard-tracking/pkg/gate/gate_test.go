@@ -0,0 +1,65 @@
+package gate
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"reflect"
+	"testing"
+)
+
+func TestMaxOutOfDateCountsEveryNode(t *testing.T) {
+	clusters := []model.ClusterWithAgentMetadata{
+		{
+			// The first agent is up to date but another node is out of date
+			ClusterInfo: model.ClusterInfo{Name: "mixed"},
+			AgentStatus: string(model.AgentStatusUpToDate),
+			AgentStatusCounts: map[string]int{
+				string(model.AgentStatusUpToDate):  2,
+				string(model.AgentStatusOutOfDate): 1,
+			},
+		},
+		{
+			ClusterInfo:       model.ClusterInfo{Name: "current"},
+			AgentStatus:       string(model.AgentStatusUpToDate),
+			AgentStatusCounts: map[string]int{string(model.AgentStatusUpToDate): 3},
+		},
+	}
+
+	thresholds := NewThresholds()
+	thresholds.MaxOutOfDate = 0
+	violations, err := Evaluate(clusters, thresholds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 || !reflect.DeepEqual(violations[0].Clusters, []string{"mixed"}) {
+		t.Errorf("got violations %+v, want max-out-of-date for the mixed cluster", violations)
+	}
+}
+
+func TestIncompleteClustersLeftOutOfChecks(t *testing.T) {
+	clusters := []model.ClusterWithAgentMetadata{
+		{
+			ClusterInfo:    model.ClusterInfo{Name: "onboarded", NodeCount: 2},
+			NodesConnected: "2",
+			RuntimeEnabled: true,
+			Dimensions:     map[string]string{"environment": "production"},
+		},
+		{
+			// The lookups timed out, leaving the defaults of a cluster without agent nor runtime
+			ClusterInfo:    model.ClusterInfo{Name: "timed-out", NodeCount: 10},
+			NodesConnected: "0",
+			Dimensions:     map[string]string{"environment": "production"},
+			Errors:         []string{"failed to get runtime data for cluster timed-out: context deadline exceeded"},
+		},
+	}
+
+	thresholds := NewThresholds()
+	thresholds.MinNodeCoverage = 100
+	thresholds.RequireRuntime = Selectors{{Dimension: "env", Value: "production"}}
+	violations, err := Evaluate(clusters, thresholds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 0 {
+		t.Errorf("got violations %+v for an incomplete cluster, want none", violations)
+	}
+}
@@ -2,7 +2,6 @@ package logging
 
 import (
 	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/config"
-	"bytes"
 	"github.com/sirupsen/logrus"
 	"net/http"
 	"os"
@@ -12,19 +11,16 @@ import (
 var Log *logrus.Entry
 
 // ResponseWriter is a minimal wrapper for http.ResponseWriter that allows the
-// written HTTP status code to be captured for logging.
+// written HTTP status code and body size to be captured for logging.
 type ResponseWriter struct {
 	http.ResponseWriter
 	status int
-	body   *bytes.Buffer
+	size   int
 }
 
 // NewResponseWriter creates a new responseWriter.
 func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
-	return &ResponseWriter{
-		ResponseWriter: w,
-		body:           &bytes.Buffer{},
-	}
+	return &ResponseWriter{ResponseWriter: w}
 }
 
 // WriteHeader saves the status code and writes it to the underlying
@@ -34,10 +30,28 @@ func (rw *ResponseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// Write writes the data to the body and underlying http.ResponseWriter.
+// Write writes the data to the underlying http.ResponseWriter, counting the bytes written
+// without keeping them.
 func (rw *ResponseWriter) Write(b []byte) (int, error) {
-	rw.body.Write(b)
-	return rw.ResponseWriter.Write(b)
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += n
+	return n, err
+}
+
+// Status returns the HTTP status code written, defaulting to 200 when none was set explicitly.
+func (rw *ResponseWriter) Status() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+// Size returns the number of body bytes written.
+func (rw *ResponseWriter) Size() int {
+	return rw.size
 }
 
 func init() {
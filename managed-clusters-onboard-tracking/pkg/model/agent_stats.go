@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type AgentData struct {
 	AgentStats AgentStats    `json:"agentStats"`
 	Details    []AgentDetail `json:"details"`
@@ -23,4 +25,13 @@ type AgentDetail struct {
 	ClusterName    string `json:"clusterName"`
 	DeploymentType string `json:"deploymentType"`
 	Containerised  bool   `json:"containerised"`
+
+	// SinceLastSeen and Stale are computed from AgentLastSeen when the data is collected
+	SinceLastSeen time.Duration `json:"sinceLastSeen,omitempty"`
+	Stale         bool          `json:"stale,omitempty"`
+}
+
+// LastSeen parses the time the agent last reported.
+func (d AgentDetail) LastSeen() (time.Time, error) {
+	return time.Parse(time.RFC3339, d.AgentLastSeen)
 }
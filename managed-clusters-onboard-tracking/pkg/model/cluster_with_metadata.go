@@ -1,9 +1,98 @@
 package model
 
+import (
+	"sort"
+	"time"
+)
+
+// ClusterWithAgentMetadata is stored as JSON in snapshots. The field names are the Go names in camel
+// case, so snapshots saved before the tags existed still decode.
 type ClusterWithAgentMetadata struct {
 	ClusterInfo
-	NodesConnected string
-	AgentStatus    string
-	AgentVersion   string
-	RuntimeEnabled bool
+	NodesConnected string `json:"nodesConnected"`
+	AgentStatus    string `json:"agentStatus"`
+	AgentVersion   string `json:"agentVersion"`
+	RuntimeEnabled bool   `json:"runtimeEnabled"`
+	// AgentDetails holds every agent record reported for the cluster, one per node
+	AgentDetails []AgentDetail `json:"agentDetails"`
+	// AgentStatusCounts is the number of agent records per status, across every node of the cluster
+	AgentStatusCounts map[string]int `json:"agentStatusCounts"`
+	// LastSeen is the most recent report of any agent of the cluster, zero when unknown
+	LastSeen      time.Time     `json:"lastSeen"`
+	SinceLastSeen time.Duration `json:"sinceLastSeen"`
+	// StaleAgents is the number of agents that did not report within the stale window,
+	// the cluster is Stale when none of its agents did
+	StaleAgents int  `json:"staleAgents"`
+	Stale       bool `json:"stale"`
+	// AgentVersions lists every distinct agent version reported by the nodes of the cluster
+	AgentVersions []string `json:"agentVersions"`
+	// VersionCompliance is the result of the agent version policy, with the reasons of non compliance
+	VersionCompliance        string   `json:"versionCompliance"`
+	VersionComplianceReasons []string `json:"versionComplianceReasons"`
+	// Runtime coverage fields are "N/A" unless every runtime result page was fetched
+	RuntimeResults         string `json:"runtimeResults"`
+	RuntimeWorkloads       string `json:"runtimeWorkloads"`
+	RuntimeVulnerabilities string `json:"runtimeVulnerabilities"`
+	// VulnerabilityPosture and Workloads are nil unless every runtime result page was fetched
+	VulnerabilityPosture *VulnerabilityPosture `json:"vulnerabilityPosture"`
+	Workloads            []RuntimeWorkload     `json:"workloads"`
+	// WorkloadSummary replaces Workloads for clusters read back from a report
+	WorkloadSummary *WorkloadSummary `json:"workloadSummary,omitempty"`
+	// Dimensions holds the classification of the cluster, such as its environment
+	Dimensions map[string]string `json:"dimensions"`
+	// Errors lists the lookups that failed for the cluster when running with partial results
+	Errors []string `json:"errors"`
+}
+
+// Incomplete reports whether a lookup of the cluster failed, leaving defaults such as no connected
+// node or no runtime in place of the actual data.
+func (c ClusterWithAgentMetadata) Incomplete() bool {
+	return len(c.Errors) > 0
+}
+
+// MixedAgentVersions reports whether nodes of the cluster run different agent versions.
+func (c ClusterWithAgentMetadata) MixedAgentVersions() bool {
+	return len(c.AgentVersions) > 1
+}
+
+// RuntimeNamespaces returns the sorted namespaces with at least one workload with runtime results.
+func (c ClusterWithAgentMetadata) RuntimeNamespaces() []string {
+	seen := make(map[string]bool)
+	var namespaces []string
+	for _, workload := range c.Workloads {
+		if !seen[workload.Namespace] {
+			seen[workload.Namespace] = true
+			namespaces = append(namespaces, workload.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// HasWorkloadCoverage reports whether the workloads with runtime results of the cluster are known.
+func (c ClusterWithAgentMetadata) HasWorkloadCoverage() bool {
+	return c.Workloads != nil || c.WorkloadSummary != nil
+}
+
+// RuntimeNamespaceCount returns the number of namespaces with at least one workload with runtime results.
+func (c ClusterWithAgentMetadata) RuntimeNamespaceCount() int {
+	if c.Workloads == nil && c.WorkloadSummary != nil {
+		return c.WorkloadSummary.Namespaces
+	}
+	return len(c.RuntimeNamespaces())
+}
+
+// WorkloadTypeCounts returns the number of workloads with runtime results per workload type.
+func (c ClusterWithAgentMetadata) WorkloadTypeCounts() map[string]int {
+	counts := make(map[string]int)
+	if c.Workloads == nil && c.WorkloadSummary != nil {
+		for workloadType, count := range c.WorkloadSummary.Types {
+			counts[workloadType] = count
+		}
+		return counts
+	}
+	for _, workload := range c.Workloads {
+		counts[workload.Type]++
+	}
+	return counts
 }
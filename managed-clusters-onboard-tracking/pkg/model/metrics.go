@@ -0,0 +1,97 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Metrics aggregates onboarding numbers over a set of clusters. Incomplete clusters are only counted
+// in ClustersIncomplete, their defaults would otherwise count as missing coverage.
+type Metrics struct {
+	Clusters               int
+	ClustersIncomplete     int
+	ClustersAgentConnected int
+	ClustersRuntimeEnabled int
+	TotalNodes             int
+	TotalNodesConnected    int
+}
+
+// GroupMetrics holds the Metrics of the clusters sharing the same Key.
+type GroupMetrics struct {
+	Key string
+	Metrics
+}
+
+// NodeCoverage returns the percentage of nodes with a connected agent.
+func (m Metrics) NodeCoverage() float64 {
+	if m.TotalNodes == 0 {
+		return 0
+	}
+	return float64(m.TotalNodesConnected) / float64(m.TotalNodes) * 100
+}
+
+// RuntimeCoverage returns the percentage of clusters with runtime enabled.
+func (m Metrics) RuntimeCoverage() float64 {
+	if m.Clusters == 0 {
+		return 0
+	}
+	return float64(m.ClustersRuntimeEnabled) / float64(m.Clusters) * 100
+}
+
+// ComputeMetrics aggregates the onboarding metrics of the given clusters.
+func ComputeMetrics(clusters []ClusterWithAgentMetadata) (Metrics, error) {
+	var metrics Metrics
+	for _, cluster := range clusters {
+		if err := metrics.add(cluster); err != nil {
+			return Metrics{}, err
+		}
+	}
+	return metrics, nil
+}
+
+// ComputeGroupMetrics aggregates the onboarding metrics of the clusters grouped by key, sorted by key.
+func ComputeGroupMetrics(clusters []ClusterWithAgentMetadata, key func(ClusterWithAgentMetadata) string) ([]GroupMetrics, error) {
+	groups := make(map[string]*GroupMetrics)
+	for _, cluster := range clusters {
+		groupKey := key(cluster)
+		group, ok := groups[groupKey]
+		if !ok {
+			group = &GroupMetrics{Key: groupKey}
+			groups[groupKey] = group
+		}
+		if err := group.add(cluster); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]GroupMetrics, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result, nil
+}
+
+func (m *Metrics) add(cluster ClusterWithAgentMetadata) error {
+	if cluster.Incomplete() {
+		m.ClustersIncomplete++
+		return nil
+	}
+
+	nodesConnected, err := strconv.Atoi(cluster.NodesConnected)
+	if err != nil {
+		return fmt.Errorf("error converting NodesConnected of cluster %s to int: %v", cluster.Name, err)
+	}
+
+	m.Clusters++
+	if cluster.AgentConnected {
+		m.ClustersAgentConnected++
+	}
+	if cluster.RuntimeEnabled {
+		m.ClustersRuntimeEnabled++
+	}
+	m.TotalNodes += cluster.NodeCount
+	m.TotalNodesConnected += nodesConnected
+	return nil
+}
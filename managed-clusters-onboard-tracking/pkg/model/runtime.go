@@ -1,9 +1,28 @@
 package model
 
+import (
+	"sort"
+	"strings"
+)
+
+// Positions of the severities in VulnsBySev and RunningVulnsBySev
+const (
+	severityCritical = iota
+	severityHigh
+	severityMedium
+	severityLow
+)
+
+// Policy evaluation results of a runtime result
+const (
+	policyPassed = "passed"
+	policyFailed = "failed"
+)
+
 type Page struct {
-	Returned int         `json:"returned"`
-	Matched  int         `json:"matched"`
-	Next     interface{} `json:"next"`
+	Returned int    `json:"returned"`
+	Matched  int    `json:"matched"`
+	Next     string `json:"next"`
 }
 
 type Labels struct {
@@ -40,4 +59,129 @@ type RuntimeData struct {
 type RuntimeCluster struct {
 	ClusterName string
 	IsEnabled   bool
+	ResultCount int
+	// Results holds every runtime result of the cluster, only populated when all pages were fetched
+	Results  []RuntimeResult
+	Complete bool
+}
+
+// WorkloadCount returns the number of distinct workloads with runtime results in the cluster.
+func (r RuntimeCluster) WorkloadCount() int {
+	workloads := make(map[string]bool)
+	for _, result := range r.Results {
+		labels := result.RecordDetails.Labels
+		workloads[labels.KubernetesNamespaceName+"/"+labels.KubernetesWorkloadType+"/"+labels.KubernetesWorkloadName] = true
+	}
+	return len(workloads)
+}
+
+// RuntimeWorkload is a workload of a cluster with runtime results.
+type RuntimeWorkload struct {
+	Namespace  string   `json:"namespace"`
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Containers []string `json:"containers"`
+	Results    int      `json:"results"`
+}
+
+// WorkloadSummary is the workload coverage of a cluster read back from a report, which keeps the
+// counts but not the workloads themselves.
+type WorkloadSummary struct {
+	Namespaces int            `json:"namespaces"`
+	Types      map[string]int `json:"types"`
+}
+
+// Workloads groups the runtime results of the cluster by workload, sorted by namespace, type and name.
+func (r RuntimeCluster) Workloads() []RuntimeWorkload {
+	byKey := make(map[string]*RuntimeWorkload)
+	var keys []string
+	for _, result := range r.Results {
+		labels := result.RecordDetails.Labels
+		key := labels.KubernetesNamespaceName + "/" + labels.KubernetesWorkloadType + "/" + labels.KubernetesWorkloadName
+		workload, ok := byKey[key]
+		if !ok {
+			workload = &RuntimeWorkload{Namespace: labels.KubernetesNamespaceName, Name: labels.KubernetesWorkloadName, Type: labels.KubernetesWorkloadType}
+			byKey[key] = workload
+			keys = append(keys, key)
+		}
+		workload.Results++
+		if container := labels.KubernetesPodContainerName; container != "" && !containsString(workload.Containers, container) {
+			workload.Containers = append(workload.Containers, container)
+		}
+	}
+
+	sort.Strings(keys)
+	workloads := make([]RuntimeWorkload, 0, len(keys))
+	for _, key := range keys {
+		sort.Strings(byKey[key].Containers)
+		workloads = append(workloads, *byKey[key])
+	}
+	return workloads
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// VulnerabilityCount returns the sum of vulnerabilities of all severities across every runtime result.
+func (r RuntimeCluster) VulnerabilityCount() int {
+	total := 0
+	for _, result := range r.Results {
+		for _, count := range result.VulnsBySev {
+			total += count
+		}
+	}
+	return total
+}
+
+// VulnerabilityPosture aggregates the vulnerabilities and policy evaluations of the runtime results of a cluster.
+type VulnerabilityPosture struct {
+	Critical     int `json:"vulns_critical"`
+	High         int `json:"vulns_high"`
+	Medium       int `json:"vulns_medium"`
+	Low          int `json:"vulns_low"`
+	Running      int `json:"running_vulns"`
+	Exploitable  int `json:"exploitable_vulns"`
+	PolicyPassed int `json:"policy_passed"`
+	PolicyFailed int `json:"policy_failed"`
+	AcceptedRisk int `json:"accepted_risk"`
+}
+
+// VulnerabilityPosture sums the vulnerabilities by severity, the running and exploitable ones,
+// and counts the results passing or failing their policies or with an accepted risk.
+func (r RuntimeCluster) VulnerabilityPosture() VulnerabilityPosture {
+	var posture VulnerabilityPosture
+	for _, result := range r.Results {
+		posture.Critical += severityCount(result.VulnsBySev, severityCritical)
+		posture.High += severityCount(result.VulnsBySev, severityHigh)
+		posture.Medium += severityCount(result.VulnsBySev, severityMedium)
+		posture.Low += severityCount(result.VulnsBySev, severityLow)
+		for _, count := range result.RunningVulnsBySev {
+			posture.Running += count
+		}
+		posture.Exploitable += result.ExploitCount
+
+		switch strings.ToLower(result.PolicyEvaluationsResult) {
+		case policyPassed:
+			posture.PolicyPassed++
+		case policyFailed:
+			posture.PolicyFailed++
+		}
+		if result.HasAcceptedRisk {
+			posture.AcceptedRisk++
+		}
+	}
+	return posture
+}
+
+func severityCount(vulnsBySev []int, severity int) int {
+	if severity < len(vulnsBySev) {
+		return vulnsBySev[severity]
+	}
+	return 0
 }
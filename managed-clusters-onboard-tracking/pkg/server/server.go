@@ -0,0 +1,118 @@
+package server
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/adapter"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/logging"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+	jsonContentType    = "application/json"
+	csvContentType     = "text/csv"
+
+	// upMetric tells whether the served metrics come from a finished collection
+	upMetric = "sysdig_onboarding_up"
+)
+
+// Server exposes the latest collected cluster snapshot over HTTP.
+type Server struct {
+	mutex     sync.RWMutex
+	clusters  []model.ClusterWithAgentMetadata
+	updatedAt time.Time
+}
+
+func NewServer() *Server {
+	return &Server{}
+}
+
+// Update replaces the snapshot served by the server.
+func (s *Server) Update(clusters []model.ClusterWithAgentMetadata) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.clusters = clusters
+	s.updatedAt = time.Now()
+}
+
+// Snapshot returns the latest clusters and when they were collected, a zero time means no collection finished yet.
+func (s *Server) Snapshot() ([]model.ClusterWithAgentMetadata, time.Time) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.clusters, s.updatedAt
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.serveMetrics)
+	mux.HandleFunc("/clusters.json", s.serveReport(adapter.JSONWriter{}, jsonContentType))
+	mux.HandleFunc("/clusters.csv", s.serveReport(adapter.CSVWriter{}, csvContentType))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return accessLog(mux)
+}
+
+// accessLog logs every request with its status, size and duration.
+func accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := logging.NewResponseWriter(w)
+
+		next.ServeHTTP(rw, r)
+
+		logging.Log.WithFields(map[string]interface{}{
+			"method":   r.Method,
+			"path":     r.URL.Path,
+			"status":   rw.Status(),
+			"size":     rw.Size(),
+			"duration": time.Since(start).String(),
+			"remote":   r.RemoteAddr,
+		}).Info("HTTP request")
+	})
+}
+
+// serveMetrics renders the latest snapshot in the Prometheus format after the up gauge. Before the
+// first collection finishes only the up gauge is served, at 0, so the target is not reported down.
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	clusters, updatedAt := s.Snapshot()
+
+	w.Header().Set("Content-Type", metricsContentType)
+	up := 0
+	if !updatedAt.IsZero() {
+		w.Header().Set("Last-Modified", updatedAt.UTC().Format(http.TimeFormat))
+		up = 1
+	}
+	fmt.Fprintf(w, "# HELP %s Whether cluster data was collected since the server started.\n", upMetric)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", upMetric)
+	fmt.Fprintf(w, "%s %d\n", upMetric, up)
+	if updatedAt.IsZero() {
+		return
+	}
+
+	if err := (adapter.PrometheusWriter{}).Write(w, clusters); err != nil {
+		logging.Log.Errorf("Error rendering %s. Error: %v", r.URL.Path, err)
+	}
+}
+
+// serveReport renders the latest snapshot with the given writer.
+func (s *Server) serveReport(writer adapter.Writer, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clusters, updatedAt := s.Snapshot()
+		if updatedAt.IsZero() {
+			http.Error(w, "cluster data not collected yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Last-Modified", updatedAt.UTC().Format(http.TimeFormat))
+		if err := writer.Write(w, clusters); err != nil {
+			logging.Log.Errorf("Error rendering %s. Error: %v", r.URL.Path, err)
+		}
+	}
+}
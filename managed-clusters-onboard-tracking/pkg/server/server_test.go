@@ -0,0 +1,58 @@
+package server
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/logging"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logging.Log = logrus.NewEntry(logger)
+	os.Exit(m.Run())
+}
+
+func get(t *testing.T, handler http.Handler, path string) (int, string) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestMetricsBeforeFirstCollection(t *testing.T) {
+	server := NewServer()
+	handler := server.Handler()
+
+	status, body := get(t, handler, "/metrics")
+	if status != http.StatusOK || !strings.Contains(body, upMetric+" 0\n") {
+		t.Errorf("/metrics answered %d with %q, want 200 with %s 0", status, body, upMetric)
+	}
+	if strings.Contains(body, "sysdig_onboarding_clusters") {
+		t.Errorf("/metrics served cluster metrics before the first collection: %q", body)
+	}
+	if status, _ := get(t, handler, "/clusters.json"); status != http.StatusServiceUnavailable {
+		t.Errorf("/clusters.json answered %d before the first collection, want 503", status)
+	}
+
+	server.Update([]model.ClusterWithAgentMetadata{{ClusterInfo: model.ClusterInfo{Name: "cluster", NodeCount: 1}, NodesConnected: "1"}})
+	status, body = get(t, handler, "/metrics")
+	if status != http.StatusOK || !strings.Contains(body, upMetric+" 1\n") || !strings.Contains(body, "sysdig_onboarding_clusters 1\n") {
+		t.Errorf("/metrics answered %d with %q after a collection, want %s 1 and the cluster metrics", status, body, upMetric)
+	}
+}
+
+func TestResponseWriterCountsBytes(t *testing.T) {
+	rw := logging.NewResponseWriter(httptest.NewRecorder())
+	rw.Write([]byte("hello "))
+	rw.Write([]byte("world"))
+	if rw.Size() != len("hello world") || rw.Status() != http.StatusOK {
+		t.Errorf("got size %d and status %d, want %d and 200", rw.Size(), rw.Status(), len("hello world"))
+	}
+}
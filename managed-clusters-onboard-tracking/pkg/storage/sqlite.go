@@ -0,0 +1,306 @@
+package storage
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/classifier"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	_ "modernc.org/sqlite"
+	"strings"
+	"time"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS snapshots (
+	id                       INTEGER PRIMARY KEY AUTOINCREMENT,
+	taken_at                 TEXT    NOT NULL,
+	clusters                 INTEGER NOT NULL,
+	clusters_agent_connected INTEGER NOT NULL,
+	clusters_runtime_enabled INTEGER NOT NULL,
+	total_nodes              INTEGER NOT NULL,
+	total_nodes_connected    INTEGER NOT NULL,
+	node_coverage            REAL    NOT NULL,
+	runtime_coverage         REAL    NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS snapshot_clusters (
+	snapshot_id      INTEGER NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+	name             TEXT    NOT NULL,
+	provider         TEXT    NOT NULL,
+	region           TEXT    NOT NULL,
+	account_id       TEXT    NOT NULL,
+	environment      TEXT    NOT NULL,
+	agent_connected  INTEGER NOT NULL,
+	node_count       INTEGER NOT NULL,
+	nodes_connected  INTEGER NOT NULL,
+	agent_status     TEXT    NOT NULL,
+	agent_version    TEXT    NOT NULL,
+	runtime_enabled  INTEGER NOT NULL,
+	incomplete       INTEGER NOT NULL DEFAULT 0,
+	data             TEXT    NOT NULL,
+	PRIMARY KEY (snapshot_id, provider, account_id, region, name)
+);
+
+CREATE INDEX IF NOT EXISTS snapshots_taken_at ON snapshots(taken_at);
+`
+
+// legacyClusterKey is the primary key of snapshot_clusters in databases created before cluster
+// names were known to repeat across accounts and regions.
+const legacyClusterKey = "PRIMARY KEY (snapshot_id, name)"
+
+// timeLayout is used to store timestamps so they sort lexicographically.
+const timeLayout = time.RFC3339
+
+// Snapshot is the stored state of every cluster at a given time.
+type Snapshot struct {
+	ID       int64
+	TakenAt  time.Time
+	Metrics  model.Metrics
+	Clusters []model.ClusterWithAgentMetadata
+}
+
+// SnapshotStore persists collected data as timestamped snapshots in a SQLite database.
+type SnapshotStore struct {
+	db *sql.DB
+}
+
+// OpenSnapshotStore opens, creating it when missing, the SQLite database at path.
+func OpenSnapshotStore(path string) (*SnapshotStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, sharing one connection avoids lock errors
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating snapshot schema in %s: %v", path, err)
+	}
+	if err := migrateClusterKey(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error migrating snapshot schema in %s: %v", path, err)
+	}
+	if err := migrateIncompleteColumn(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error migrating snapshot schema in %s: %v", path, err)
+	}
+	return &SnapshotStore{db: db}, nil
+}
+
+// migrateClusterKey rebuilds a snapshot_clusters table keyed by cluster name only, which rejected
+// clusters sharing a name in different accounts or regions, with the current primary key.
+func migrateClusterKey(db *sql.DB) error {
+	var table string
+	if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'snapshot_clusters'`).Scan(&table); err != nil {
+		return err
+	}
+	if !strings.Contains(table, legacyClusterKey) {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const columns = "snapshot_id, name, provider, region, account_id, environment, agent_connected, node_count, nodes_connected, agent_status, agent_version, runtime_enabled, data"
+	statements := []string{
+		`ALTER TABLE snapshot_clusters RENAME TO snapshot_clusters_legacy`,
+		schema,
+		`INSERT INTO snapshot_clusters (` + columns + `) SELECT ` + columns + ` FROM snapshot_clusters_legacy ORDER BY rowid`,
+		`DROP TABLE snapshot_clusters_legacy`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// migrateIncompleteColumn adds the incomplete column to snapshot_clusters tables created without it,
+// clusters stored before are considered complete.
+func migrateIncompleteColumn(db *sql.DB) error {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('snapshot_clusters') WHERE name = 'incomplete'`).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := db.Exec(`ALTER TABLE snapshot_clusters ADD COLUMN incomplete INTEGER NOT NULL DEFAULT 0`)
+	return err
+}
+
+func (s *SnapshotStore) Close() error {
+	return s.db.Close()
+}
+
+// SaveSnapshot stores the clusters and their aggregate metrics, returning the new snapshot ID.
+func (s *SnapshotStore) SaveSnapshot(takenAt time.Time, clusters []model.ClusterWithAgentMetadata) (int64, error) {
+	metrics, err := model.ComputeMetrics(clusters)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO snapshots (taken_at, clusters, clusters_agent_connected, clusters_runtime_enabled, total_nodes, total_nodes_connected, node_coverage, runtime_coverage)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		takenAt.UTC().Format(timeLayout), metrics.Clusters, metrics.ClustersAgentConnected, metrics.ClustersRuntimeEnabled,
+		metrics.TotalNodes, metrics.TotalNodesConnected, metrics.NodeCoverage(), metrics.RuntimeCoverage())
+	if err != nil {
+		return 0, err
+	}
+	snapshotID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	statement, err := tx.Prepare(`INSERT INTO snapshot_clusters (snapshot_id, name, provider, region, account_id, environment, agent_connected, node_count, nodes_connected, agent_status, agent_version, runtime_enabled, incomplete, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer statement.Close()
+
+	for _, cluster := range clusters {
+		// The full cluster is kept as JSON so any past report can be rebuilt as it was
+		data, err := json.Marshal(cluster)
+		if err != nil {
+			return 0, err
+		}
+		var nodesConnected int
+		fmt.Sscan(cluster.NodesConnected, &nodesConnected)
+
+		_, err = statement.Exec(snapshotID, cluster.Name, cluster.Provider, cluster.Region, cluster.AccountID, cluster.Dimensions[classifier.EnvironmentDimension],
+			cluster.AgentConnected, cluster.NodeCount, nodesConnected, cluster.AgentStatus, cluster.AgentVersion, cluster.RuntimeEnabled, cluster.Incomplete(), string(data))
+		if err != nil {
+			return 0, fmt.Errorf("error storing cluster %s: %v", cluster.Name, err)
+		}
+	}
+
+	return snapshotID, tx.Commit()
+}
+
+// ListSnapshots returns every snapshot without its clusters, oldest first.
+func (s *SnapshotStore) ListSnapshots() ([]Snapshot, error) {
+	rows, err := s.db.Query(`SELECT id, taken_at, clusters, clusters_agent_connected, clusters_runtime_enabled, total_nodes, total_nodes_connected
+		FROM snapshots ORDER BY taken_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		snapshot, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+// LoadSnapshot returns the snapshot with the given ID including all its clusters.
+func (s *SnapshotStore) LoadSnapshot(id int64) (Snapshot, error) {
+	row := s.db.QueryRow(`SELECT id, taken_at, clusters, clusters_agent_connected, clusters_runtime_enabled, total_nodes, total_nodes_connected
+		FROM snapshots WHERE id = ?`, id)
+	snapshot, err := scanSnapshot(row)
+	if err == sql.ErrNoRows {
+		return Snapshot{}, fmt.Errorf("snapshot %d not found", id)
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	rows, err := s.db.Query(`SELECT data FROM snapshot_clusters WHERE snapshot_id = ? ORDER BY rowid`, id)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return Snapshot{}, err
+		}
+		var cluster model.ClusterWithAgentMetadata
+		if err := json.Unmarshal([]byte(data), &cluster); err != nil {
+			return Snapshot{}, fmt.Errorf("error decoding cluster of snapshot %d: %v", id, err)
+		}
+		snapshot.Clusters = append(snapshot.Clusters, cluster)
+	}
+	return snapshot, rows.Err()
+}
+
+// GroupSnapshot holds the metrics of the clusters of a snapshot sharing the same value of a column.
+type GroupSnapshot struct {
+	ID      int64
+	TakenAt time.Time
+	model.GroupMetrics
+}
+
+// groupColumns lists the snapshot_clusters columns snapshots can be grouped by.
+var groupColumns = map[string]bool{"provider": true, "region": true, "environment": true, "agent_status": true}
+
+// ListGroupSnapshots aggregates every snapshot per value of column, oldest snapshot first. Like the
+// snapshot metrics, incomplete clusters are left out.
+func (s *SnapshotStore) ListGroupSnapshots(column string) ([]GroupSnapshot, error) {
+	if !groupColumns[column] {
+		return nil, fmt.Errorf("snapshots cannot be grouped by %q", column)
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(`SELECT s.id, s.taken_at, c.%[1]s, COUNT(*), SUM(c.agent_connected), SUM(c.runtime_enabled), SUM(c.node_count), SUM(c.nodes_connected)
+		FROM snapshots s JOIN snapshot_clusters c ON c.snapshot_id = s.id
+		WHERE c.incomplete = 0
+		GROUP BY s.id, c.%[1]s ORDER BY s.taken_at, s.id, c.%[1]s`, column))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []GroupSnapshot
+	for rows.Next() {
+		var group GroupSnapshot
+		var takenAt string
+		err := rows.Scan(&group.ID, &takenAt, &group.Key, &group.Clusters, &group.ClustersAgentConnected, &group.ClustersRuntimeEnabled,
+			&group.TotalNodes, &group.TotalNodesConnected)
+		if err != nil {
+			return nil, err
+		}
+		if group.TakenAt, err = time.Parse(timeLayout, takenAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSnapshot(row scanner) (Snapshot, error) {
+	var snapshot Snapshot
+	var takenAt string
+	err := row.Scan(&snapshot.ID, &takenAt, &snapshot.Metrics.Clusters, &snapshot.Metrics.ClustersAgentConnected, &snapshot.Metrics.ClustersRuntimeEnabled,
+		&snapshot.Metrics.TotalNodes, &snapshot.Metrics.TotalNodesConnected)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot.TakenAt, err = time.Parse(timeLayout, takenAt)
+	return snapshot, err
+}
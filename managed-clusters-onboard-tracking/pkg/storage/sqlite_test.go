@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sameNamedClusters returns clusters sharing a name across accounts and regions.
+func sameNamedClusters() []model.ClusterWithAgentMetadata {
+	return []model.ClusterWithAgentMetadata{
+		{ClusterInfo: model.ClusterInfo{Name: "prod", Provider: "aws", AccountID: "1", Region: "us-east-1", NodeCount: 3}, NodesConnected: "3"},
+		{ClusterInfo: model.ClusterInfo{Name: "prod", Provider: "aws", AccountID: "2", Region: "us-east-1", NodeCount: 2}, NodesConnected: "0"},
+		{ClusterInfo: model.ClusterInfo{Name: "prod", Provider: "aws", AccountID: "1", Region: "eu-west-1", NodeCount: 1}, NodesConnected: "1"},
+	}
+}
+
+func TestSaveSnapshotWithSameNamedClusters(t *testing.T) {
+	store, err := OpenSnapshotStore(filepath.Join(t.TempDir(), "snapshots.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	clusters := sameNamedClusters()
+	id, err := store.SaveSnapshot(time.Now(), clusters)
+	if err != nil {
+		t.Fatalf("SaveSnapshot returned an error: %v", err)
+	}
+
+	snapshot, err := store.LoadSnapshot(id)
+	if err != nil {
+		t.Fatalf("LoadSnapshot returned an error: %v", err)
+	}
+	if len(snapshot.Clusters) != len(clusters) {
+		t.Fatalf("got %d clusters, want %d", len(snapshot.Clusters), len(clusters))
+	}
+	for i, cluster := range snapshot.Clusters {
+		if cluster.AccountID != clusters[i].AccountID || cluster.Region != clusters[i].Region || cluster.NodeCount != clusters[i].NodeCount {
+			t.Errorf("cluster %d is %+v, want %+v", i, cluster.ClusterInfo, clusters[i].ClusterInfo)
+		}
+	}
+}
+
+func TestOpenSnapshotStoreMigratesLegacyKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots.db")
+
+	// Create a database with the schema keyed by cluster name only, holding one snapshot
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := strings.Replace(schema, "PRIMARY KEY (snapshot_id, provider, account_id, region, name)", legacyClusterKey, 1)
+	if _, err := db.Exec(legacy); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO snapshots (taken_at, clusters, clusters_agent_connected, clusters_runtime_enabled, total_nodes, total_nodes_connected, node_coverage, runtime_coverage)
+		VALUES ('2024-01-01T00:00:00Z', 1, 0, 0, 1, 0, 0, 0)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO snapshot_clusters (snapshot_id, name, provider, region, account_id, environment, agent_connected, node_count, nodes_connected, agent_status, agent_version, runtime_enabled, data)
+		VALUES (1, 'legacy', 'aws', 'us-east-1', '1', 'production', 0, 1, 0, 'N/A', 'N/A', 0, '{"name": "legacy"}')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	store, err := OpenSnapshotStore(path)
+	if err != nil {
+		t.Fatalf("OpenSnapshotStore returned an error: %v", err)
+	}
+	defer store.Close()
+
+	if snapshot, err := store.LoadSnapshot(1); err != nil || len(snapshot.Clusters) != 1 || snapshot.Clusters[0].Name != "legacy" {
+		t.Errorf("legacy snapshot loaded as %+v, %v", snapshot.Clusters, err)
+	}
+	if _, err := store.SaveSnapshot(time.Now(), sameNamedClusters()); err != nil {
+		t.Errorf("SaveSnapshot returned an error after the migration: %v", err)
+	}
+}
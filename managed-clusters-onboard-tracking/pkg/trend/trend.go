@@ -0,0 +1,170 @@
+package trend
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/model"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Supported aggregation periods
+const (
+	PeriodDay  = "day"
+	PeriodWeek = "week"
+)
+
+// OverallGroup is the group name of the metrics over every cluster.
+const OverallGroup = "all"
+
+var sparklineLevels = []rune("▁▂▃▄▅▆▇█")
+
+// Sample is the metrics of a group of clusters at the time a snapshot was taken.
+type Sample struct {
+	TakenAt time.Time
+	Group   string
+	Metrics model.Metrics
+}
+
+// Point is the state of a group at the end of a period, taken from its latest snapshot.
+type Point struct {
+	Period          string  `json:"period"`
+	Group           string  `json:"group"`
+	Clusters        int     `json:"clusters"`
+	TotalNodes      int     `json:"total_nodes"`
+	NodesConnected  int     `json:"nodes_connected"`
+	NodeCoverage    float64 `json:"node_coverage"`
+	RuntimeCoverage float64 `json:"runtime_coverage"`
+}
+
+// Build keeps the latest sample of every group in each period, sorted by group then period.
+func Build(samples []Sample, period string) ([]Point, error) {
+	type key struct{ group, period string }
+	latest := make(map[key]Sample)
+
+	for _, sample := range samples {
+		label, err := periodLabel(sample.TakenAt, period)
+		if err != nil {
+			return nil, err
+		}
+		k := key{group: sample.Group, period: label}
+		if current, ok := latest[k]; !ok || !sample.TakenAt.Before(current.TakenAt) {
+			latest[k] = sample
+		}
+	}
+
+	points := make([]Point, 0, len(latest))
+	for k, sample := range latest {
+		points = append(points, Point{
+			Period:          k.period,
+			Group:           k.group,
+			Clusters:        sample.Metrics.Clusters,
+			TotalNodes:      sample.Metrics.TotalNodes,
+			NodesConnected:  sample.Metrics.TotalNodesConnected,
+			NodeCoverage:    sample.Metrics.NodeCoverage(),
+			RuntimeCoverage: sample.Metrics.RuntimeCoverage(),
+		})
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Group != points[j].Group {
+			return points[i].Group < points[j].Group
+		}
+		return points[i].Period < points[j].Period
+	})
+	return points, nil
+}
+
+func periodLabel(takenAt time.Time, period string) (string, error) {
+	takenAt = takenAt.UTC()
+	switch period {
+	case PeriodDay:
+		return takenAt.Format("2006-01-02"), nil
+	case PeriodWeek:
+		year, week := takenAt.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week), nil
+	default:
+		return "", fmt.Errorf("unsupported trend period %q", period)
+	}
+}
+
+// WriteCSV writes one row per group and period.
+func WriteCSV(w io.Writer, points []Point) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"period", "group", "clusters", "total_nodes", "nodes_connected", "node_coverage", "runtime_coverage"})
+	for _, point := range points {
+		writer.Write([]string{
+			point.Period,
+			point.Group,
+			strconv.Itoa(point.Clusters),
+			strconv.Itoa(point.TotalNodes),
+			strconv.Itoa(point.NodesConnected),
+			strconv.FormatFloat(point.NodeCoverage, 'f', 2, 64),
+			strconv.FormatFloat(point.RuntimeCoverage, 'f', 2, 64),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSON writes the points as an indented JSON array.
+func WriteJSON(w io.Writer, points []Point) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(points)
+}
+
+// WriteText writes, per group, the latest values and sparklines of node and runtime coverage.
+func WriteText(w io.Writer, points []Point) error {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "GROUP\tPERIODS\tNODE COVERAGE\tCONNECTED NODES\tRUNTIME COVERAGE\tNODE COVERAGE TREND\tRUNTIME COVERAGE TREND")
+
+	for start := 0; start < len(points); {
+		end := start
+		for end < len(points) && points[end].Group == points[start].Group {
+			end++
+		}
+		series := points[start:end]
+		last := series[len(series)-1]
+
+		var nodeCoverage, runtimeCoverage []float64
+		for _, point := range series {
+			nodeCoverage = append(nodeCoverage, point.NodeCoverage)
+			runtimeCoverage = append(runtimeCoverage, point.RuntimeCoverage)
+		}
+		fmt.Fprintf(writer, "%s\t%s..%s\t%.1f%%\t%d/%d\t%.1f%%\t%s\t%s\n", last.Group, series[0].Period, last.Period,
+			last.NodeCoverage, last.NodesConnected, last.TotalNodes, last.RuntimeCoverage, Sparkline(nodeCoverage), Sparkline(runtimeCoverage))
+		start = end
+	}
+	return writer.Flush()
+}
+
+// Sparkline renders values as a line of block characters scaled between their minimum and maximum.
+func Sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	min, max := values[0], values[0]
+	for _, value := range values {
+		if value < min {
+			min = value
+		}
+		if value > max {
+			max = value
+		}
+	}
+
+	var line strings.Builder
+	for _, value := range values {
+		level := len(sparklineLevels) / 2
+		if max > min {
+			level = int((value - min) / (max - min) * float64(len(sparklineLevels)-1))
+		}
+		line.WriteRune(sparklineLevels[level])
+	}
+	return line.String()
+}
@@ -0,0 +1,115 @@
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Compliance values reported per cluster
+const (
+	Compliant    = "compliant"
+	NonCompliant = "non-compliant"
+	NotEvaluated = "N/A"
+)
+
+// Policy describes the supported agent versions.
+type Policy struct {
+	// MinVersion is the lowest supported version, empty means no minimum
+	MinVersion string `json:"min_version"`
+	// AllowedMajors lists the supported major lines, empty means every major is allowed
+	AllowedMajors []int `json:"allowed_majors"`
+	// EOL lists end of life versions, a MAJOR.MINOR entry covers every patch of that line
+	EOL []string `json:"eol"`
+
+	minVersion *Version
+	eol        []eolEntry
+}
+
+// eolEntry is a parsed EOL entry, parts is the number of components it gives.
+type eolEntry struct {
+	version Version
+	parts   int
+}
+
+// LoadPolicy reads a version policy from a JSON file.
+func LoadPolicy(path string) (*Policy, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &Policy{}
+	if err := json.Unmarshal(content, policy); err != nil {
+		return nil, fmt.Errorf("error parsing version policy %s: %v", path, err)
+	}
+	if policy.MinVersion != "" {
+		minVersion, err := Parse(policy.MinVersion)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing version policy %s: %v", path, err)
+		}
+		policy.minVersion = &minVersion
+	}
+	// A typo in an entry would silently turn it off, so every entry must parse
+	for _, value := range policy.EOL {
+		version, parts, err := parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing version policy %s: eol: %v", path, err)
+		}
+		policy.eol = append(policy.eol, eolEntry{version: version, parts: parts})
+	}
+	return policy, nil
+}
+
+// Evaluate checks every version against the policy and returns whether all of them comply,
+// along with the reasons of non compliance.
+func (p *Policy) Evaluate(versions []string) (bool, []string) {
+	var reasons []string
+	for _, value := range versions {
+		reasons = append(reasons, p.evaluate(value)...)
+	}
+	return len(reasons) == 0, reasons
+}
+
+func (p *Policy) evaluate(value string) []string {
+	parsed, err := Parse(value)
+	if err != nil {
+		return []string{fmt.Sprintf("%s is not a valid version", value)}
+	}
+
+	var reasons []string
+	if p.minVersion != nil && parsed.Compare(*p.minVersion) < 0 {
+		reasons = append(reasons, fmt.Sprintf("%s is below the minimum version %s", value, p.MinVersion))
+	}
+	if len(p.AllowedMajors) > 0 && !containsInt(p.AllowedMajors, parsed.Major) {
+		reasons = append(reasons, fmt.Sprintf("%s is not in an allowed major line", value))
+	}
+	for _, eol := range p.eol {
+		if isEOL(parsed, eol) {
+			reasons = append(reasons, fmt.Sprintf("%s is end of life", value))
+			break
+		}
+	}
+	return reasons
+}
+
+// isEOL matches a version against an EOL entry, which may omit the patch or minor to cover a whole line.
+func isEOL(v Version, eol eolEntry) bool {
+	switch eol.parts {
+	case 1:
+		return v.Major == eol.version.Major
+	case 2:
+		return v.Major == eol.version.Major && v.Minor == eol.version.Minor
+	default:
+		return v.Compare(eol.version) == 0
+	}
+}
+
+func containsInt(values []int, value int) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
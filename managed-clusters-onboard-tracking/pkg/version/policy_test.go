@@ -0,0 +1,79 @@
+package version
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsEOL(t *testing.T) {
+	tests := []struct {
+		version string
+		eol     string
+		want    bool
+	}{
+		{version: "12.1.0", eol: "12", want: true},
+		{version: "13.0.0", eol: "12", want: false},
+		{version: "12.1.5", eol: "12.1", want: true},
+		{version: "12.2.0", eol: "12.1", want: false},
+		{version: "12.1.0", eol: "12.1.0", want: true},
+		{version: "12.1.1", eol: "12.1.0", want: false},
+		// Suffixes do not change how many components an entry gives
+		{version: "12.1.0", eol: "12.1.0+build.5", want: true},
+		{version: "12.1.3", eol: "12.1-rc1", want: true},
+	}
+	for _, test := range tests {
+		v, err := Parse(test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		eol, parts, err := parse(test.eol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := isEOL(v, eolEntry{version: eol, parts: parts}); got != test.want {
+			t.Errorf("isEOL(%s, %s) = %v, want %v", test.version, test.eol, got, test.want)
+		}
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		wantErr bool
+	}{
+		{name: "valid", policy: `{"min_version": "12.10.0", "eol": ["11", "12.1", "12.2.0"]}`},
+		{name: "invalid min version", policy: `{"min_version": "twelve"}`, wantErr: true},
+		{name: "invalid eol entry", policy: `{"eol": ["11", "12.x"]}`, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.json")
+			if err := os.WriteFile(path, []byte(test.policy), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadPolicy(path); (err != nil) != test.wantErr {
+				t.Errorf("LoadPolicy error = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(`{"min_version": "12.10.0", "allowed_majors": [12, 13], "eol": ["12.11"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if compliant, reasons := policy.Evaluate([]string{"12.16.1", "13.0.0"}); !compliant {
+		t.Errorf("supported versions reported non-compliant: %v", reasons)
+	}
+	if compliant, reasons := policy.Evaluate([]string{"12.11.2", "12.9.0", "14.0.0", "N/A"}); compliant || len(reasons) != 4 {
+		t.Errorf("got compliant %v with reasons %v, want 4 reasons", compliant, reasons)
+	}
+}
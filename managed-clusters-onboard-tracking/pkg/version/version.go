@@ -0,0 +1,60 @@
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version of the Sysdig agent, such as 12.15.0.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// Parse reads a MAJOR[.MINOR[.PATCH]] version, ignoring a leading "v" and any pre-release or build suffix.
+func Parse(value string) (Version, error) {
+	version, _, err := parse(value)
+	return version, err
+}
+
+// parse reads a version like Parse and also returns the number of components it was given.
+func parse(value string) (Version, int, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(value), "v")
+	if index := strings.IndexAny(trimmed, "-+ "); index >= 0 {
+		trimmed = trimmed[:index]
+	}
+
+	parts := strings.Split(trimmed, ".")
+	if trimmed == "" || len(parts) > 3 {
+		return Version{}, 0, fmt.Errorf("invalid version %q", value)
+	}
+
+	var numbers [3]int
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return Version{}, 0, fmt.Errorf("invalid version %q", value)
+		}
+		numbers[i] = number
+	}
+	return Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, len(parts), nil
+}
+
+// Compare returns -1, 0 or 1 when v is lower than, equal to or greater than other.
+func (v Version) Compare(other Version) int {
+	for _, pair := range [][2]int{{v.Major, other.Major}, {v.Minor, other.Minor}, {v.Patch, other.Patch}} {
+		if pair[0] < pair[1] {
+			return -1
+		}
+		if pair[0] > pair[1] {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
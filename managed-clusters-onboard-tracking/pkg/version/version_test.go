@@ -0,0 +1,54 @@
+package version
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Version
+		wantErr bool
+	}{
+		{value: "12.15.0", want: Version{12, 15, 0}},
+		{value: "v13.0.1", want: Version{13, 0, 1}},
+		{value: " 12.1 ", want: Version{12, 1, 0}},
+		{value: "12", want: Version{12, 0, 0}},
+		{value: "12.1.0-rc1", want: Version{12, 1, 0}},
+		{value: "12.1.0+build.5", want: Version{12, 1, 0}},
+		{value: "", wantErr: true},
+		{value: "N/A", wantErr: true},
+		{value: "12.x", wantErr: true},
+		{value: "1.2.3.4", wantErr: true},
+		{value: "12.-1.0", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Parse(%q) error = %v, want error %v", test.value, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Parse(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "12.10.0", b: "12.9.0", want: 1},
+		{a: "12.9.0", b: "12.10.0", want: -1},
+		{a: "13.0.0", b: "12.99.99", want: 1},
+		{a: "12.1.1", b: "12.1.0", want: 1},
+		{a: "12.1", b: "12.1.0", want: 0},
+		{a: "12.1.0+build.5", b: "12.1.0", want: 0},
+	}
+	for _, test := range tests {
+		a, _ := Parse(test.a)
+		b, _ := Parse(test.b)
+		if got := a.Compare(b); got != test.want {
+			t.Errorf("%s compared to %s = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package worker
+
+import "sync"
+
+// Pool runs submitted tasks on a fixed number of workers. Tasks can be tagged with a kind
+// so that the number of in-flight tasks of that kind is capped independently.
+type Pool struct {
+	tasks   chan task
+	limits  map[string]*kindLimit
+	backlog int
+	mu      sync.Mutex
+	space   *sync.Cond
+	wg      sync.WaitGroup
+}
+
+type task struct {
+	kind string
+	fn   func()
+}
+
+// kindLimit tracks the tasks of a limited kind. Tasks submitted while every slot is taken wait
+// in pending and are run by the worker that frees a slot.
+type kindLimit struct {
+	limit   int
+	running int
+	pending []func()
+}
+
+// NewPool starts a pool with the given number of workers. limits maps a task kind to the
+// maximum number of tasks of that kind running at once; kinds without a positive limit
+// are only bounded by the number of workers.
+func NewPool(workers int, limits map[string]int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &Pool{
+		tasks:   make(chan task),
+		limits:  make(map[string]*kindLimit),
+		backlog: workers,
+	}
+	p.space = sync.NewCond(&p.mu)
+	for kind, limit := range limits {
+		if limit > 0 {
+			p.limits[kind] = &kindLimit{limit: limit}
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues a task and blocks until a worker picks it up. A task of a limited kind whose
+// slots are all taken is set aside instead, so workers never sit idle waiting on a limit while
+// tasks of other kinds are queued; Submit only blocks on it once as many tasks of that kind as
+// there are workers are already waiting, which keeps the backlog bounded.
+func (p *Pool) Submit(kind string, fn func()) {
+	p.wg.Add(1)
+	limit, ok := p.limits[kind]
+	if !ok {
+		p.tasks <- task{kind: kind, fn: fn}
+		return
+	}
+
+	p.mu.Lock()
+	for len(limit.pending) >= p.backlog {
+		p.space.Wait()
+	}
+	if limit.running < limit.limit {
+		limit.running++
+		p.mu.Unlock()
+		p.tasks <- task{kind: kind, fn: fn}
+		return
+	}
+	limit.pending = append(limit.pending, fn)
+	p.mu.Unlock()
+}
+
+// Wait blocks until every submitted task has finished and stops the workers.
+// The pool must not be used after Wait returns.
+func (p *Pool) Wait() {
+	p.wg.Wait()
+	close(p.tasks)
+}
+
+func (p *Pool) work() {
+	for t := range p.tasks {
+		fn := t.fn
+		for fn != nil {
+			fn()
+			p.wg.Done()
+			fn = p.next(t.kind)
+		}
+	}
+}
+
+// next frees the slot of a finished task and returns the task of the same kind that was waiting
+// for it, if any, so the slot is handed over without going through Submit again.
+func (p *Pool) next(kind string) func() {
+	limit, ok := p.limits[kind]
+	if !ok {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(limit.pending) == 0 {
+		limit.running--
+		return nil
+	}
+	fn := limit.pending[0]
+	limit.pending = limit.pending[1:]
+	p.space.Broadcast()
+	return fn
+}
@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolLimitDoesNotStarveOtherKinds(t *testing.T) {
+	pool := NewPool(4, map[string]int{"agent": 1})
+
+	// The only agent slot is held until the runtime tasks are done
+	release := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		pool.Submit("agent", func() { <-release })
+	}
+
+	var done int32
+	finished := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			pool.Submit("runtime", func() { atomic.AddInt32(&done, 1) })
+		}
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("runtime tasks blocked behind the agent limit, %d of 10 ran", atomic.LoadInt32(&done))
+	}
+	close(release)
+	pool.Wait()
+
+	if got := atomic.LoadInt32(&done); got != 10 {
+		t.Errorf("%d runtime tasks ran, want 10", got)
+	}
+}
+
+func TestPoolEnforcesLimit(t *testing.T) {
+	pool := NewPool(8, map[string]int{"agent": 2})
+
+	var running, maxRunning int32
+	for i := 0; i < 20; i++ {
+		pool.Submit("agent", func() {
+			current := atomic.AddInt32(&running, 1)
+			for {
+				previous := atomic.LoadInt32(&maxRunning)
+				if current <= previous || atomic.CompareAndSwapInt32(&maxRunning, previous, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		})
+	}
+	pool.Wait()
+
+	if maxRunning > 2 {
+		t.Errorf("%d agent tasks ran at once, want at most 2", maxRunning)
+	}
+}
+
+func TestPoolSubmitBlocksOnceBacklogIsFull(t *testing.T) {
+	pool := NewPool(1, map[string]int{"agent": 1})
+
+	// One task runs, one waits for the slot and the third has no room left
+	release := make(chan struct{})
+	pool.Submit("agent", func() { <-release })
+	pool.Submit("agent", func() {})
+
+	submitted := make(chan struct{})
+	go func() {
+		pool.Submit("agent", func() {})
+		close(submitted)
+	}()
+
+	select {
+	case <-submitted:
+		t.Fatal("Submit returned while the backlog of its kind was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	select {
+	case <-submitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Submit stayed blocked after the backlog drained")
+	}
+	pool.Wait()
+}
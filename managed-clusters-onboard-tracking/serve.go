@@ -0,0 +1,106 @@
+package main
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/classifier"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/client"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/config"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/logging"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/server"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/version"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	serveCommand = "serve"
+
+	// Time given to in-flight HTTP requests when shutting down
+	shutdownTimeout = 5
+)
+
+// runServe runs as a daemon, collecting the cluster data on a schedule and serving the latest snapshot until interrupted.
+func runServe(arguments []string) {
+	flagSet, args := newFlagSet(os.Args[0] + " " + serveCommand)
+	flagSet.StringVar(&args.Listen, "listen", config.Config.ListenAddress, "Address the HTTP server listens on")
+	flagSet.DurationVar(&args.Interval, "interval", time.Duration(config.Config.RefreshInterval)*time.Minute, "Interval between data collections (0 collects only once)")
+	flagSet.Parse(arguments)
+
+	// Lookup failures must not stop the server, they are reported per cluster instead
+	args.PartialResults = true
+
+	sysdigClient := client.NewClient(config.Config)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	classificationRules, err := loadClassificationRules(args.RulesFile)
+	if err != nil {
+		logging.Log.Fatal("error loading classification rules: ", err)
+		return
+	}
+
+	versionPolicy, err := loadVersionPolicy(args.VersionPolicyFile)
+	if err != nil {
+		logging.Log.Fatal("error loading agent version policy: ", err)
+		return
+	}
+
+	metricsServer := server.NewServer()
+	httpServer := &http.Server{Addr: args.Listen, Handler: metricsServer.Handler()}
+
+	// Collect right away, then refresh the snapshot on every interval
+	go func() {
+		refreshSnapshot(ctx, sysdigClient, *args, classificationRules, versionPolicy, metricsServer)
+		if args.Interval <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(args.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				refreshSnapshot(ctx, sysdigClient, *args, classificationRules, versionPolicy, metricsServer)
+			}
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	logging.Log.Infof("Serving /metrics, /clusters.json and /clusters.csv on %s, refreshing every %s", args.Listen, args.Interval)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logging.Log.Fatal("error serving metrics: ", err)
+	}
+}
+
+// refreshSnapshot collects the cluster data and replaces the snapshot served, keeping the previous one on failure.
+func refreshSnapshot(ctx context.Context, sysdigClient client.API, args CommandLineArgs, rules *classifier.Rules, versionPolicy *version.Policy, metricsServer *server.Server) {
+	start := time.Now()
+	clusters, err := collectClusterData(ctx, sysdigClient, args, rules, versionPolicy)
+	if err != nil {
+		logging.Log.Error(err)
+		return
+	}
+	if ctx.Err() != nil {
+		// Interrupted collections are incomplete, the previous snapshot is kept
+		return
+	}
+	metricsServer.Update(clusters)
+	logging.Log.Infof("Collected data of %d clusters in %s", len(clusters), time.Since(start))
+
+	if args.SnapshotDB != "" {
+		saveSnapshot(args.SnapshotDB, start, clusters)
+	}
+}
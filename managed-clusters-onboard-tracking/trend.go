@@ -0,0 +1,104 @@
+package main
+
+import (
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/config"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/logging"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/storage"
+	"IgorEulalio/sysdig-helpers/managed-clusters-onboard-tracking/pkg/trend"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const trendCommand = "trend"
+
+// runTrend reports how onboarding evolved across the stored snapshots.
+func runTrend(arguments []string) {
+	flagSet := flag.NewFlagSet(os.Args[0]+" "+trendCommand, flag.ExitOnError)
+	snapshotDB := flagSet.String("snapshot-db", config.Config.SnapshotDB, "SQLite database holding the snapshots")
+	period := flagSet.String("period", trend.PeriodDay, "Aggregation period: day or week")
+	groupBy := flagSet.String("by", trend.OverallGroup, "Group the trend by: all, provider or environment")
+	format := flagSet.String("format", "text", "Output format: text, csv or json")
+	output := flagSet.String("output", "", "Output file name, the trend is printed to the terminal when empty")
+	flagSet.Parse(arguments)
+
+	if *snapshotDB == "" {
+		logging.Log.Fatal("trend requires --snapshot-db")
+		return
+	}
+
+	store, err := storage.OpenSnapshotStore(*snapshotDB)
+	if err != nil {
+		logging.Log.Fatal("error opening snapshot database: ", err)
+		return
+	}
+	defer store.Close()
+
+	samples, err := loadTrendSamples(store, *groupBy)
+	if err != nil {
+		logging.Log.Fatal("error loading snapshots: ", err)
+		return
+	}
+
+	points, err := trend.Build(samples, *period)
+	if err != nil {
+		logging.Log.Fatal(err)
+		return
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			logging.Log.Fatal("error creating trend output: ", err)
+			return
+		}
+		defer file.Close()
+		out = file
+	}
+
+	switch *format {
+	case "text":
+		err = trend.WriteText(out, points)
+	case "csv":
+		err = trend.WriteCSV(out, points)
+	case "json":
+		err = trend.WriteJSON(out, points)
+	default:
+		err = fmt.Errorf("unsupported trend format %q", *format)
+	}
+	if err != nil {
+		logging.Log.Fatal("error writing trend: ", err)
+		return
+	}
+
+	// Sparklines are always shown in the terminal when the trend goes to a file
+	if *output != "" {
+		trend.WriteText(os.Stdout, points)
+	}
+}
+
+func loadTrendSamples(store *storage.SnapshotStore, groupBy string) ([]trend.Sample, error) {
+	var samples []trend.Sample
+
+	if groupBy == trend.OverallGroup {
+		snapshots, err := store.ListSnapshots()
+		if err != nil {
+			return nil, err
+		}
+		for _, snapshot := range snapshots {
+			samples = append(samples, trend.Sample{TakenAt: snapshot.TakenAt, Group: trend.OverallGroup, Metrics: snapshot.Metrics})
+		}
+		return samples, nil
+	}
+
+	groups, err := store.ListGroupSnapshots(groupBy)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		samples = append(samples, trend.Sample{TakenAt: group.TakenAt, Group: group.Key, Metrics: group.Metrics})
+	}
+	return samples, nil
+}